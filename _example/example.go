@@ -7,7 +7,7 @@ import (
 )
 
 type User struct {
-	Name string `borm:"PRIMARY KEY"`
+	Name string `borm:"primaryKey"`
 	Age  int
 }
 
@@ -45,40 +45,40 @@ func main() {
 
 	// 单条查询
 	tmp := User{}
-	if err := s.Where("Name = ?", "tomygin").First(&tmp); err != nil {
+	if err := s.Where("name = ?", "tomygin").First(&tmp); err != nil {
 		log.Error(err)
 	}
 
 	// 多条查询
 	tmps := []User{}
-	if err := s.Where("Age > 10").Find(&tmps); err == nil {
+	if err := s.Where("age > 10").Find(&tmps); err == nil {
 		log.Info("拿到数据", tmps)
 	}
 
 	// 分页查询
 	// Page 仅仅是封装了 Limit 和 Offset
-	if err := s.Where("Age > 10").Page(1, 2).Find(&tmps); err == nil {
+	if err := s.Where("age > 10").Page(1, 2).Find(&tmps); err == nil {
 		log.Info("分页查询到数据", tmps)
 	}
 
 	// 删除
-	if _, err := s.Where("Age = ?", 18).Limit(1).Delete(); err != nil {
+	if _, err := s.Where("age = ?", 18).Limit(1).Delete(); err != nil {
 		log.Error(err)
 	}
 
 	// 更新
-	s.Where("Name = ?", "tomygin").Update("Age", 18)
+	s.Where("name = ?", "tomygin").Update("Age", 18)
 
 	// 查看更新
-	s.Where("Name = ?", "tomygin").First(&tmp)
+	s.Where("name = ?", "tomygin").First(&tmp)
 	log.Info(tmp)
 
 	// 排序查找最小年龄
-	s.OrderBy("Age DESC").First(&tmp)
+	s.OrderBy("age DESC").First(&tmp)
 	log.Info(tmp)
 
 	// 执行原生SQL
-	s.Raw("INSERT INTO User (`Name`)  VALUES (?) ", "RAW").Exec()
+	s.Raw("INSERT INTO users (name) VALUES (?)", "RAW").Exec()
 
 	// 一键事务，失败自动回滚
 	r, err := engine.Transaction(func(s *session.Session) (interface{}, error) {
@@ -87,7 +87,7 @@ func main() {
 		s.CreateTable()
 		s.Insert(&User{Name: "tomygin"})
 		t := User{}
-		err := s.Where("Name = ?", "tomygin").First(&t)
+		err := s.Where("name = ?", "tomygin").First(&t)
 		return t, err
 	})
 	log.Info(r, err)
@@ -5,26 +5,98 @@
 // Package borm implements a ORM framework
 package borm
 
-import "github.com/tomygin/borm/session"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tomygin/borm/log"
+	"github.com/tomygin/borm/session"
+)
 
 // 事务的回调函数
 type TxFunc func(*session.Session) (interface{}, error)
 
+// TxOptions是TransactionWith的选项
+type TxOptions struct {
+	Isolation sql.IsolationLevel //隔离级别，零值使用数据库的默认级别
+	ReadOnly  bool               //只读事务
+	Retry     RetryPolicy        //数据库繁忙或者序列化失败时的重试策略
+}
+
+// RetryPolicy是事务的重试策略，每次重试前等待的时间翻倍
+type RetryPolicy struct {
+	MaxRetries int           //最多重试的次数，0表示不重试
+	Backoff    time.Duration //第一次重试前等待的时间，0表示10ms
+	MaxBackoff time.Duration //等待时间的上限，0表示没有上限
+}
+
+// delay返回第attempt次重试前等待的时间，attempt从0开始
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = 10 * time.Millisecond
+	}
+	for i := 0; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff) && d < math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
 // Transaction一键事务提交，如果失败自动回滚
 func (e *Engine) Transaction(f TxFunc) (result interface{}, err error) {
-	s := e.NewSession()
-	if err := s.Begin(); err != nil {
-		return nil, err
+	return e.TransactionContext(context.Background(), f)
+}
+
+// TransactionContext和Transaction一样，但事务和其中的sql都受ctx控制
+// f中可以调用s.Transaction嵌套事务，嵌套的事务使用保存点
+// f中panic时事务回滚，panic不会继续向上传递，而是作为错误返回
+func (e *Engine) TransactionContext(ctx context.Context, f TxFunc) (result interface{}, err error) {
+	return e.TransactionWithContext(ctx, nil, f)
+}
+
+// TransactionWith和Transaction一样，但可以指定隔离级别和只读
+// 数据库繁忙(SQLITE_BUSY)、死锁或者序列化失败时按opts.Retry重新执行整个事务，所以f可能被执行多次
+func (e *Engine) TransactionWith(opts *TxOptions, f TxFunc) (interface{}, error) {
+	return e.TransactionWithContext(context.Background(), opts, f)
+}
+
+// TransactionWithContext和TransactionWith一样，但事务和其中的sql都受ctx控制，ctx结束时不再重试
+func (e *Engine) TransactionWithContext(ctx context.Context, opts *TxOptions, f TxFunc) (result interface{}, err error) {
+	var txOpts *sql.TxOptions
+	var retry RetryPolicy
+	if opts != nil {
+		txOpts = &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+		retry = opts.Retry
 	}
 
-	defer func() {
-		if p := recover(); p != nil {
-			_ = s.RollBack()
-		} else if err != nil {
-			_ = s.RollBack()
-		} else {
-			err = s.Commit()
+	for attempt := 0; ; attempt++ {
+		s := e.NewSession().WithContext(ctx)
+		err = s.TransactionWith(txOpts, func(s *session.Session) (err error) {
+			// f中的panic被恢复为错误，事务回滚
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("transaction panic: %v", p)
+					log.Error(err)
+				}
+			}()
+			result, err = f(s)
+			return
+		})
+		if err == nil || attempt >= retry.MaxRetries || !e.dialect.IsRetryable(err) {
+			return
+		}
+
+		log.Errorf("transaction failed: %v, retry %d/%d\n", err, attempt+1, retry.MaxRetries)
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(retry.delay(attempt)):
 		}
-	}()
-	return f(s)
+	}
 }
@@ -1,6 +1,10 @@
 package clause
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/tomygin/borm/dialect"
+)
 
 type Type int
 
@@ -15,14 +19,33 @@ const (
 	UPDATE
 	DELETE
 	COUNT
+	JOINS
+	GROUPBY
+	HAVING
+	ONCONFLICT
+	RETURNING
 )
 
+// Columns用于指定判断冲突的列，比如 s.OnConflict(clause.Columns("name")).DoNothing()
+func Columns(names ...string) []string {
+	return names
+}
+
 // Clause用于记录生成的子sql语句
 // 比如 Limit 1
 // 这里的sql是map的原因可以生成  VALUES （？，？，？） ， a,b,c
 type Clause struct {
 	sql     map[Type]string
 	sqlVars map[Type][]interface{}
+
+	where   Condition       //多次调用Where累积的条件，Build时才生成WHERE子句
+	having  Condition       //多次调用Having累积的条件，Build时才生成HAVING子句
+	dialect dialect.Dialect //生成子句时用于给表名和字段名加引号
+}
+
+// New生成一个使用方言d的Clause
+func New(d dialect.Dialect) *Clause {
+	return &Clause{dialect: d}
 }
 
 // Set用于给Clause里面添加子句
@@ -31,11 +54,21 @@ func (c *Clause) Set(name Type, vars ...interface{}) {
 		c.sql = make(map[Type]string)
 		c.sqlVars = make(map[Type][]interface{})
 	}
-	sql, vars := generators[name](vars...)
+	sql, vars := generators[name](c.dialect, vars...)
 	c.sql[name] = sql
 	c.sqlVars[name] = vars
 }
 
+// Where返回累积的WHERE条件，可以在上面继续添加条件
+func (c *Clause) Where() *Condition {
+	return &c.where
+}
+
+// Having返回累积的HAVING条件，可以在上面继续添加条件
+func (c *Clause) Having() *Condition {
+	return &c.having
+}
+
 // Build的作用是将所有的子句sql拼接为一个完整的sql语句
 // oeders是需要提取的子句sql，并且生成的完整sql也是按照这个顺序生成的
 // 比如 INSERT VALUES 最后生成 INSET INTO TABLENAME (col1,col2) , (vaule1_1,vaule2_1),(value1_2,value2_2)
@@ -45,8 +78,17 @@ func (c *Clause) Build(orders ...Type) (string, []interface{}) {
 	defer func() {
 		c.sql = nil
 		c.sqlVars = nil
+		c.where = Condition{}
+		c.having = Condition{}
 	}()
 
+	if !c.where.Empty() {
+		c.Set(WHERE, &c.where)
+	}
+	if !c.having.Empty() {
+		c.Set(HAVING, &c.having)
+	}
+
 	var sqls []string
 	var vars []interface{}
 	for _, order := range orders {
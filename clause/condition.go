@@ -0,0 +1,157 @@
+package clause
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+)
+
+// Condition是WHERE子句的条件树
+// 多个条件按调用顺序拼接，Where用AND连接，Or用OR连接，Not取反
+// 每次调用都和之前的全部条件组合，Where(a).Or(b).Where(c) 是 (a OR b) AND c
+type Condition struct {
+	exprs []expr
+}
+
+// expr是条件树的一个节点，desc和group只有一个有效
+type expr struct {
+	or    bool // 和前一个条件用OR连接
+	not   bool
+	desc  string
+	vars  []interface{}
+	group *Condition // 用括号包裹的一组条件
+}
+
+// Cond生成一个条件分组，比如
+// s.Where("Age > ?", 10).Where(clause.Cond("Name = ?", "a").Or("Name = ?", "b"))
+// 最后生成 WHERE (Age > ?) AND ((Name = ?) OR (Name = ?))
+func Cond(query interface{}, args ...interface{}) *Condition {
+	return new(Condition).Where(query, args...)
+}
+
+// Where用AND连接一个条件，query可以是字符串也可以是Cond生成的分组
+func (c *Condition) Where(query interface{}, args ...interface{}) *Condition {
+	return c.add(false, false, query, args)
+}
+
+// Or用OR连接一个条件
+func (c *Condition) Or(query interface{}, args ...interface{}) *Condition {
+	return c.add(true, false, query, args)
+}
+
+// Not用AND连接一个取反的条件
+func (c *Condition) Not(query interface{}, args ...interface{}) *Condition {
+	return c.add(false, true, query, args)
+}
+
+// Empty判断是否没有任何条件
+func (c *Condition) Empty() bool {
+	return c == nil || len(c.exprs) == 0
+}
+
+func (c *Condition) add(or, not bool, query interface{}, args []interface{}) *Condition {
+	e := expr{or: or, not: not}
+	switch q := query.(type) {
+	case *Condition:
+		if q.Empty() {
+			return c
+		}
+		e.group = q
+	case string:
+		e.desc, e.vars = q, args
+	default:
+		panic("clause: condition must be string or *Condition")
+	}
+	c.exprs = append(c.exprs, e)
+	return c
+}
+
+// Build将条件树生成sql，变量的顺序和占位符的顺序一致
+// 只有一个条件的时候不会添加括号，OR之后的AND条件会先把之前的条件用括号包裹
+func (c *Condition) Build() (string, []interface{}) {
+	var sql strings.Builder
+	var vars []interface{}
+	hasOr := false
+	for i, e := range c.exprs {
+		if i > 0 {
+			if e.or {
+				hasOr = true
+				sql.WriteString(" OR ")
+			} else if hasOr {
+				// AND的优先级比OR高，不加括号时 a OR b AND c 会变成 a OR (b AND c)
+				prev := sql.String()
+				sql.Reset()
+				sql.WriteString("(" + prev + ") AND ")
+				hasOr = false
+			} else {
+				sql.WriteString(" AND ")
+			}
+		}
+		if e.not {
+			sql.WriteString("NOT ")
+		}
+
+		desc, v := expandSlices(e.desc, e.vars)
+		if e.group != nil {
+			desc, v = e.group.Build()
+		}
+		if len(c.exprs) > 1 || e.not {
+			desc = "(" + desc + ")"
+		}
+		sql.WriteString(desc)
+		vars = append(vars, v...)
+	}
+	return sql.String(), vars
+}
+
+// expandSlices将切片类型的变量展开，对应的 ? 也会展开为 ?, ?, ?
+// 比如 ("id IN (?)", []int{1, 2}) 为 ("id IN (?, ?)", 1, 2)，空切片的 ? 会被替换为 NULL
+// 数组、[]byte 这样元素是byte的切片和实现了driver.Valuer的切片被视为一个变量
+func expandSlices(desc string, vars []interface{}) (string, []interface{}) {
+	expand := false
+	for _, v := range vars {
+		if isSlice(v) {
+			expand = true
+		}
+	}
+	if !expand {
+		return desc, vars
+	}
+
+	var sql strings.Builder
+	var expanded []interface{}
+	index := 0
+	for i := 0; i < len(desc); i++ {
+		if desc[i] != '?' || index >= len(vars) {
+			sql.WriteByte(desc[i])
+			continue
+		}
+		v := vars[index]
+		index++
+		if !isSlice(v) {
+			sql.WriteByte('?')
+			expanded = append(expanded, v)
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Len() == 0 {
+			sql.WriteString("NULL")
+			continue
+		}
+		sql.WriteString(genBuildVars(rv.Len()))
+		for j := 0; j < rv.Len(); j++ {
+			expanded = append(expanded, rv.Index(j).Interface())
+		}
+	}
+	return sql.String(), append(expanded, vars[index:]...)
+}
+
+// isSlice判断v是不是需要展开的切片，数组、元素是byte的切片(比如json.RawMessage)
+// 以及实现了driver.Valuer的类型(比如uuid.UUID)都是一个变量
+func isSlice(v interface{}) bool {
+	if _, ok := v.(driver.Valuer); ok {
+		return false
+	}
+	typ := reflect.TypeOf(v)
+	return typ != nil && typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8
+}
@@ -0,0 +1,139 @@
+package clause
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// tags实现了driver.Valuer，作为一个逗号分隔的字符串写入
+type tags []string
+
+func (t tags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+func TestConditionBuild(t *testing.T) {
+	tests := []struct {
+		name string
+		cond *Condition
+		sql  string
+		vars []interface{}
+	}{
+		{
+			name: "single",
+			cond: Cond("a = ?", 1),
+			sql:  "a = ?",
+			vars: []interface{}{1},
+		},
+		{
+			name: "and",
+			cond: Cond("a = ?", 1).Where("b = ?", 2),
+			sql:  "(a = ?) AND (b = ?)",
+			vars: []interface{}{1, 2},
+		},
+		{
+			name: "or",
+			cond: Cond("a = ?", 1).Or("b = ?", 2),
+			sql:  "(a = ?) OR (b = ?)",
+			vars: []interface{}{1, 2},
+		},
+		{
+			name: "and after or",
+			cond: Cond("a = ?", 1).Or("b = ?", 2).Where("c = ?", 3),
+			sql:  "((a = ?) OR (b = ?)) AND (c = ?)",
+			vars: []interface{}{1, 2, 3},
+		},
+		{
+			name: "or after and",
+			cond: Cond("a = ?", 1).Where("b = ?", 2).Or("c = ?", 3),
+			sql:  "(a = ?) AND (b = ?) OR (c = ?)",
+			vars: []interface{}{1, 2, 3},
+		},
+		{
+			name: "and after or twice",
+			cond: Cond("a").Or("b").Where("c").Or("d").Not("e"),
+			sql:  "(((a) OR (b)) AND (c) OR (d)) AND NOT (e)",
+		},
+		{
+			name: "not",
+			cond: new(Condition).Not("a = ?", 1),
+			sql:  "NOT (a = ?)",
+			vars: []interface{}{1},
+		},
+		{
+			name: "group",
+			cond: Cond("a = ?", 1).Where(Cond("b = ?", 2).Or("c = ?", 3)),
+			sql:  "(a = ?) AND ((b = ?) OR (c = ?))",
+			vars: []interface{}{1, 2, 3},
+		},
+		{
+			name: "empty group is skipped",
+			cond: Cond("a = ?", 1).Or(new(Condition)),
+			sql:  "a = ?",
+			vars: []interface{}{1},
+		},
+		{
+			name: "slice",
+			cond: Cond("id IN (?) AND name = ?", []int{1, 2}, "a"),
+			sql:  "id IN (?, ?) AND name = ?",
+			vars: []interface{}{1, 2, "a"},
+		},
+		{
+			name: "empty slice",
+			cond: Cond("id IN (?)", []int{}),
+			sql:  "id IN (NULL)",
+		},
+		{
+			name: "uuid is not expanded",
+			cond: Cond("id = ?", uuid.UUID{1, 2, 3}),
+			sql:  "id = ?",
+			vars: []interface{}{uuid.UUID{1, 2, 3}},
+		},
+		{
+			name: "array is not expanded",
+			cond: Cond("id = ?", [2]int{1, 2}),
+			sql:  "id = ?",
+			vars: []interface{}{[2]int{1, 2}},
+		},
+		{
+			name: "named byte slice is not expanded",
+			cond: Cond("data = ?", json.RawMessage(`{"a":1}`)),
+			sql:  "data = ?",
+			vars: []interface{}{json.RawMessage(`{"a":1}`)},
+		},
+		{
+			name: "valuer slice is not expanded",
+			cond: Cond("tags = ?", tags{"a", "b"}),
+			sql:  "tags = ?",
+			vars: []interface{}{tags{"a", "b"}},
+		},
+		{
+			name: "slice of uuids is expanded",
+			cond: Cond("id IN (?)", []uuid.UUID{{1}, {2}}),
+			sql:  "id IN (?, ?)",
+			vars: []interface{}{uuid.UUID{1}, uuid.UUID{2}},
+		},
+		{
+			name: "bytes are not expanded",
+			cond: Cond("data = ?", []byte("ab")),
+			sql:  "data = ?",
+			vars: []interface{}{[]byte("ab")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, vars := tt.cond.Build()
+			if sql != tt.sql {
+				t.Errorf("sql = %q, want %q", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(vars, tt.vars) {
+				t.Errorf("vars = %v, want %v", vars, tt.vars)
+			}
+		})
+	}
+}
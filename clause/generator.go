@@ -3,10 +3,14 @@ package clause
 import (
 	"fmt"
 	"strings"
+	"unicode"
+
+	"github.com/tomygin/borm/dialect"
 )
 
 // generator类型的函数专门用于生成构造sql子句，这里定义出来方便统一标准
-type generator func(values ...interface{}) (string, []interface{})
+// d是当前的方言，用于给表名和字段名加引号
+type generator func(d dialect.Dialect, values ...interface{}) (string, []interface{})
 
 // generators装载各种构建sql子句的函数，供Set生成子句保存在Clause里面
 var generators map[Type]generator
@@ -20,17 +24,54 @@ func genBuildVars(num int) string {
 	return strings.Join(vars, ", ")
 }
 
+// quote用方言的引号包裹标识符，table.col 会分别包裹
+// 只处理普通的标识符，像 count(*)、Age AS a 这样的表达式保持原样
+func quote(d dialect.Dialect, name string) string {
+	if d == nil || !isIdent(name) {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i := range parts {
+		parts[i] = d.Quote(parts[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteAll对每一个标识符调用quote
+func quoteAll(d dialect.Dialect, names []string) []string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quote(d, name))
+	}
+	return quoted
+}
+
+// isIdent判断name是不是 由字母数字下划线组成的标识符，允许用 . 连接
+func isIdent(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if part == "" || unicode.IsDigit(rune(part[0])) {
+			return false
+		}
+		for _, r := range part {
+			if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // _insert的第一个参数是表名，后面的参数分别是数据库字段名
 // 最后生成 INSTERT INTO TableName (col1,col2)
-func _insert(values ...interface{}) (string, []interface{}) {
-	tableName := values[0]
-	fields := strings.Join(values[1].([]string), ",")
+func _insert(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	tableName := quote(d, values[0].(string))
+	fields := strings.Join(quoteAll(d, values[1].([]string)), ",")
 	return fmt.Sprintf("INSERT INTO %s (%v) ", tableName, fields), []interface{}{}
 }
 
 // _values的入参是一个二维空接口切片，一级切片确定每一行，二级切片确定每一行的字段
 // 最后生成 VALUES (?,?...),(?,?...)	vars
-func _values(values ...interface{}) (string, []interface{}) {
+func _values(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
 	var BuildStr string
 	var sql strings.Builder
 	var vars []interface{}
@@ -56,42 +97,51 @@ func _values(values ...interface{}) (string, []interface{}) {
 	return sql.String(), vars
 }
 
-// _select第一个参数是表名，后面的参数分别是数据库字段名
-// 最后生成 SELECT col1,col2 ... FROM TableName
-func _select(values ...interface{}) (string, []interface{}) {
-	tableName := values[0]
-	fields := strings.Join(values[1].([]string), ",")
+// _select第一个参数是表名，第二个参数是数据库字段名，第三个参数为true时去重，可以省略
+// 最后生成 SELECT col1,col2 ... FROM TableName 或 SELECT DISTINCT col1,col2 ... FROM TableName
+func _select(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	tableName := quote(d, values[0].(string))
+	fields := strings.Join(quoteAll(d, values[1].([]string)), ",")
+	if len(values) > 2 && values[2].(bool) {
+		fields = "DISTINCT " + fields
+	}
 	return fmt.Sprintf("SELECT %v FROM %s ", fields, tableName), []interface{}{}
 }
 
-func _limit(values ...interface{}) (string, []interface{}) {
+func _limit(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
 	return "LIMIT ?", values
 }
 
-func _offset(values ...interface{}) (string, []interface{}) {
+func _offset(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
 	return "OFFSET ?", values
 }
 
-func _where(values ...interface{}) (string, []interface{}) {
-	desc, vars := values[0], values[1:]
+// _where的参数可以是一个*Condition，也可以是 条件语句和它的变量
+// 最后生成 WHERE (cond1) AND (cond2) ...
+func _where(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	cond, ok := values[0].(*Condition)
+	if !ok {
+		cond = Cond(values[0], values[1:]...)
+	}
+	desc, vars := cond.Build()
 	return fmt.Sprintf("WHERE %s", desc), vars
 }
 
 // _orderBy只识别第一个参数作为排序的标准
 // 最后生成 ORDER BY arg
-func _orderBy(values ...interface{}) (string, []interface{}) {
+func _orderBy(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
 	return fmt.Sprintf("ORDER BY %s", values[0]), []interface{}{}
 }
 
 // _update的第一个参数是表名，第二个是map[string]interface{}保存需要更新的键值对
 // 最后生成 UPDATE TableName SET field1 = ?,field2 =?   var1,var2
-func _update(values ...interface{}) (string, []interface{}) {
-	tableName := values[0]
+func _update(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	tableName := quote(d, values[0].(string))
 	m := values[1].(map[string]interface{})
 	var keys []string
 	var vars []interface{}
 	for k, v := range m {
-		keys = append(keys, k+" = ?")
+		keys = append(keys, quote(d, k)+" = ?")
 		vars = append(vars, v)
 	}
 	return fmt.Sprintf("UPDATE %s SET %s", tableName, strings.Join(keys, ", ")), vars
@@ -99,14 +149,57 @@ func _update(values ...interface{}) (string, []interface{}) {
 
 // _delete只识别第一个参数作为从某个表删除
 // 最后生成 DELETE FROM TableName
-func _delete(values ...interface{}) (string, []interface{}) {
-	return fmt.Sprintf("DELETE FROM %s", values[0]), []interface{}{}
+func _delete(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	return fmt.Sprintf("DELETE FROM %s", quote(d, values[0].(string))), []interface{}{}
 }
 
 // _count唯一一个参数是表名
 // 最后生成 SELECT TableName count(*)
-func _count(values ...interface{}) (string, []interface{}) {
-	return _select(values[0], []string{"count(*)"})
+func _count(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	return _select(d, values[0], []string{"count(*)"})
+}
+
+// _joins的第一个参数是所有的连接语句，后面的参数是它们的变量
+// 最后生成 LEFT JOIN t2 ON ... JOIN t3 ON ...
+func _joins(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	return values[0].(string), values[1:]
+}
+
+// _groupBy的参数是分组的列名
+// 最后生成 GROUP BY col1, col2
+func _groupBy(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	var names []string
+	for _, v := range values {
+		names = append(names, v.(string))
+	}
+	return fmt.Sprintf("GROUP BY %s", strings.Join(quoteAll(d, names), ", ")), []interface{}{}
+}
+
+// _having和_where一样，参数可以是一个*Condition，也可以是 条件语句和它的变量
+// 最后生成 HAVING (cond1) AND (cond2) ...
+func _having(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	cond, ok := values[0].(*Condition)
+	if !ok {
+		cond = Cond(values[0], values[1:]...)
+	}
+	desc, vars := cond.Build()
+	return fmt.Sprintf("HAVING %s", desc), vars
+}
+
+// _onConflict唯一一个参数是方言的ConflictSql生成的处理冲突的子句
+// 比如 ON CONFLICT (col) DO UPDATE SET col2 = excluded.col2 或 ON DUPLICATE KEY UPDATE col2 = VALUES(col2)
+func _onConflict(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	return values[0].(string), []interface{}{}
+}
+
+// _returning的参数是插入后需要返回的列名
+// 最后生成 RETURNING col1, col2
+func _returning(d dialect.Dialect, values ...interface{}) (string, []interface{}) {
+	var names []string
+	for _, v := range values {
+		names = append(names, v.(string))
+	}
+	return fmt.Sprintf("RETURNING %s", strings.Join(quoteAll(d, names), ", ")), []interface{}{}
 }
 
 func init() {
@@ -121,4 +214,9 @@ func init() {
 	generators[UPDATE] = _update
 	generators[DELETE] = _delete
 	generators[COUNT] = _count
+	generators[JOINS] = _joins
+	generators[GROUPBY] = _groupBy
+	generators[HAVING] = _having
+	generators[ONCONFLICT] = _onConflict
+	generators[RETURNING] = _returning
 }
@@ -1,12 +1,81 @@
 package dialect
 
-import "reflect"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Column描述建表时的一列，由schema根据tag解析得到，交给方言生成列定义
+type Column struct {
+	Name          string // 列名
+	Type          string // DataType转化后的类型
+	Size          int    // 字符串的长度，为0时使用方言的默认值
+	PrimaryKey    bool
+	AutoIncrement bool
+	Unique        bool
+	NotNull       bool
+	HasDefault    bool
+	Default       string // 原样写入建表语句，字符串需要自带引号
+}
+
+// Conflict描述插入的行和已有的行冲突时的处理方式，交给方言生成对应的子句
+type Conflict struct {
+	Columns   []string // 判断冲突的列，mysql由主键和唯一索引判断，不使用它
+	Updates   []string // 冲突时用插入的值更新的列
+	DoNothing bool     // 冲突时忽略插入的行
+	Primary   string   // 主键列，mysql忽略插入的行时把它更新为自己
+}
+
+// GeneratedKeys描述插入后如何取得数据库生成的主键
+type GeneratedKeys int
+
+const (
+	// LastInsertID 只有单行插入的LastInsertId可靠，多行插入时需要逐行插入，RETURNING返回的行顺序不确定
+	LastInsertID GeneratedKeys = iota
+	// Returning 总是用RETURNING，驱动不支持LastInsertId
+	Returning
+	// FirstInsertID 不支持RETURNING，多行插入时LastInsertId是第一行的主键，后面的行依次加1
+	// 前提是一条语句的自增值是连续分配的，比如mysql的innodb_autoinc_lock_mode为0或1
+	FirstInsertID
+)
+
+// ErrConflictColumns表示数据库需要指定判断冲突的列才能在冲突时更新，比如postgres
+var ErrConflictColumns = errors.New("on conflict do update requires conflict columns")
 
 var dialectsMap = map[string]Dialect{}
 
 type Dialect interface {
 	DataType(typ reflect.Value) string
 	TableExistSql(tableName string) (string, []interface{})
+	// BindVar返回第index个占位符的写法，index从1开始
+	BindVar(index int) string
+	// Quote给表名、字段名加上引号，避免和关键字冲突，比如 Order、Group
+	Quote(name string) string
+	// ColumnSql生成建表语句中列名后面的部分，包括类型和约束
+	ColumnSql(c *Column) string
+	// ColumnsSql生成查询表中已有列的sql语句，每一行依次为 列名、类型
+	ColumnsSql(tableName string) (string, []interface{})
+	// IndexesSql生成查询表中已有索引的sql语句，每一行为 索引名
+	IndexesSql(tableName string) (string, []interface{})
+	// ConflictSql生成INSERT语句后面处理冲突的子句，比如 ON CONFLICT ... DO UPDATE SET ...
+	// 数据库不支持c描述的处理方式时返回错误
+	ConflictSql(c *Conflict) (string, error)
+	// GeneratedKeys返回插入后取得数据库生成的主键的方式
+	GeneratedKeys() GeneratedKeys
+	// SavepointSql生成在事务中创建保存点的sql语句
+	SavepointSql(name string) string
+	// RollbackToSql生成回滚到保存点的sql语句
+	RollbackToSql(name string) string
+	// ReleaseSavepointSql生成释放保存点的sql语句，保存点之后的修改保留在事务中
+	ReleaseSavepointSql(name string) string
+	// IsRetryable判断err是不是重新执行整个事务可能成功的错误，比如数据库繁忙、死锁、序列化失败
+	IsRetryable(err error) bool
+	// BeginTx开启事务，驱动不支持的事务选项返回错误
+	// end不为nil时在事务提交或者回滚之后调用，err是提交或者回滚返回的错误
+	BeginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (tx *sql.Tx, end func(err error), err error)
 }
 
 // RegisterDialect 将方言注册进全局字典dialectsMap
@@ -19,3 +88,106 @@ func GetDialect(name string) (dialect Dialect, ok bool) {
 	dialect, ok = dialectsMap[name]
 	return
 }
+
+// Rebind将sql语句中的 ? 占位符替换为方言的占位符
+// 比如postgres会被替换为 $1, $2 ...，引号里面的 ? 不会被替换
+func Rebind(d Dialect, query string) string {
+	if d.BindVar(1) == "?" {
+		return query
+	}
+
+	var sql strings.Builder
+	var quote byte
+	index := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			// 在引号里面，遇到相同的引号才结束
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			index++
+			sql.WriteString(d.BindVar(index))
+			continue
+		}
+		sql.WriteByte(c)
+	}
+	return sql.String()
+}
+
+// constraints生成各个数据库通用的列约束
+// 比如 PRIMARY KEY UNIQUE NOT NULL DEFAULT 0
+func constraints(c *Column) string {
+	var sql strings.Builder
+	if c.PrimaryKey {
+		sql.WriteString(" PRIMARY KEY")
+	}
+	if c.Unique {
+		sql.WriteString(" UNIQUE")
+	}
+	if c.NotNull {
+		sql.WriteString(" NOT NULL")
+	}
+	if c.HasDefault {
+		sql.WriteString(" DEFAULT " + c.Default)
+	}
+	return sql.String()
+}
+
+// onConflict生成sqlite和postgres通用的 ON CONFLICT 子句
+// 插入的值通过 excluded 表引用
+func onConflict(d Dialect, c *Conflict) string {
+	var sql strings.Builder
+	sql.WriteString("ON CONFLICT")
+	if len(c.Columns) > 0 {
+		columns := make([]string, 0, len(c.Columns))
+		for _, name := range c.Columns {
+			columns = append(columns, d.Quote(name))
+		}
+		sql.WriteString(" (" + strings.Join(columns, ", ") + ")")
+	}
+	if c.DoNothing || len(c.Updates) == 0 {
+		sql.WriteString(" DO NOTHING")
+		return sql.String()
+	}
+	sets := make([]string, 0, len(c.Updates))
+	for _, name := range c.Updates {
+		sets = append(sets, d.Quote(name)+" = excluded."+d.Quote(name))
+	}
+	sql.WriteString(" DO UPDATE SET " + strings.Join(sets, ", "))
+	return sql.String()
+}
+
+// savepoint生成各个数据库通用的创建保存点的语句
+func savepoint(d Dialect, name string) string {
+	return "SAVEPOINT " + d.Quote(name)
+}
+
+// rollbackTo生成各个数据库通用的回滚到保存点的语句
+func rollbackTo(d Dialect, name string) string {
+	return "ROLLBACK TO SAVEPOINT " + d.Quote(name)
+}
+
+// beginTx用database/sql默认的方式开启事务，驱动自己处理事务选项
+func beginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*sql.Tx, func(error), error) {
+	tx, err := db.BeginTx(ctx, opts)
+	return tx, nil, err
+}
+
+// releaseSavepoint生成各个数据库通用的释放保存点的语句
+func releaseSavepoint(d Dialect, name string) string {
+	return "RELEASE SAVEPOINT " + d.Quote(name)
+}
+
+// sqlState返回驱动错误中的SQLSTATE，比如postgres驱动的错误实现了 SQLState() string
+func sqlState(err error) string {
+	var e interface{ SQLState() string }
+	if errors.As(err, &e) {
+		return e.SQLState()
+	}
+	return ""
+}
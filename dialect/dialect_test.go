@@ -0,0 +1,98 @@
+package dialect
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	postgres, _ := GetDialect("postgres")
+	sqlite, _ := GetDialect("sqlite")
+	mysql, _ := GetDialect("mysql")
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{"postgres", postgres, "SELECT * FROM users WHERE a = ? AND b = ?", "SELECT * FROM users WHERE a = $1 AND b = $2"},
+		{"postgres no vars", postgres, "SELECT * FROM users", "SELECT * FROM users"},
+		{"postgres single quote", postgres, "SELECT * FROM users WHERE a = '?' AND b = ?", "SELECT * FROM users WHERE a = '?' AND b = $1"},
+		{"postgres double quote", postgres, `SELECT "a?" FROM users WHERE b = ?`, `SELECT "a?" FROM users WHERE b = $1`},
+		{"postgres escaped quote", postgres, "SELECT * FROM users WHERE a = 'it''s ?' AND b = ?", "SELECT * FROM users WHERE a = 'it''s ?' AND b = $1"},
+		{"postgres many", postgres, "VALUES (?, ?), (?, ?), (?, ?), (?, ?), (?, ?)", "VALUES ($1, $2), ($3, $4), ($5, $6), ($7, $8), ($9, $10)"},
+		{"sqlite", sqlite, "SELECT * FROM users WHERE a = ?", "SELECT * FROM users WHERE a = ?"},
+		{"mysql", mysql, "SELECT * FROM `users` WHERE a = ?", "SELECT * FROM `users` WHERE a = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rebind(tt.dialect, tt.query); got != tt.want {
+				t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflictSql(t *testing.T) {
+	postgres, _ := GetDialect("postgres")
+	sqlite, _ := GetDialect("sqlite")
+	mysql, _ := GetDialect("mysql")
+
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		conflict *Conflict
+		want     string
+		err      error
+	}{
+		{"sqlite do nothing", sqlite, &Conflict{Columns: []string{"name"}, DoNothing: true}, `ON CONFLICT ("name") DO NOTHING`, nil},
+		{"sqlite do update", sqlite, &Conflict{Columns: []string{"name"}, Updates: []string{"age"}}, `ON CONFLICT ("name") DO UPDATE SET "age" = excluded."age"`, nil},
+		{"postgres do nothing", postgres, &Conflict{DoNothing: true}, `ON CONFLICT DO NOTHING`, nil},
+		{"postgres do update", postgres, &Conflict{Columns: []string{"name"}, Updates: []string{"age"}}, `ON CONFLICT ("name") DO UPDATE SET "age" = excluded."age"`, nil},
+		{"postgres do update without columns", postgres, &Conflict{Updates: []string{"age"}, Primary: "id"}, "", ErrConflictColumns},
+		{"sqlite do update without columns", sqlite, &Conflict{Updates: []string{"age"}}, `ON CONFLICT DO UPDATE SET "age" = excluded."age"`, nil},
+		{"mysql do update", mysql, &Conflict{Updates: []string{"age", "name"}}, "ON DUPLICATE KEY UPDATE `age` = VALUES(`age`), `name` = VALUES(`name`)", nil},
+		{"mysql do nothing", mysql, &Conflict{Columns: []string{"name"}, Updates: []string{"age"}, DoNothing: true, Primary: "id"}, "ON DUPLICATE KEY UPDATE `id` = `id`", nil},
+		{"mysql do nothing without primary", mysql, &Conflict{Columns: []string{"name"}, DoNothing: true}, "ON DUPLICATE KEY UPDATE `name` = `name`", nil},
+		{"mysql do nothing without columns", mysql, &Conflict{DoNothing: true, Primary: "id"}, "ON DUPLICATE KEY UPDATE `id` = `id`", nil},
+		{"mysql do update without updates", mysql, &Conflict{Primary: "id"}, "ON DUPLICATE KEY UPDATE `id` = `id`", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.dialect.ConflictSql(tt.conflict)
+			if got != tt.want || err != tt.err {
+				t.Errorf("ConflictSql = %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+// mysqlError和go-sql-driver的*mysql.MySQLError一样，错误码在Number字段中
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlError) Error() string { return e.Message }
+
+func TestMysqlIsRetryable(t *testing.T) {
+	mysql, _ := GetDialect("mysql")
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysqlError{Number: 1213, Message: "deadlock"}, true},
+		{&mysqlError{Number: 1205, Message: "lock wait timeout"}, true},
+		{fmt.Errorf("insert: %w", &mysqlError{Number: 1213}), true},
+		{&mysqlError{Number: 1062, Message: "duplicate entry"}, false},
+		{errors.New("deadlock"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := mysql.IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// mysql方言不内置驱动，使用前请自行导入，比如 _ "github.com/go-sql-driver/mysql"
+// 需要把datetime扫描为time.Time时，连接参数请带上 parseTime=true
+type mysql struct{}
+
+// 在编译期检测mysql结构体是否实现了Dialect接口
+var _ Dialect = (*mysql)(nil)
+
+func init() {
+	RegisterDialect("mysql", &mysql{})
+}
+
+// DataType将go的数据类型转化为mysql的数据类型
+func (m *mysql) DataType(typ reflect.Value) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "tinyint(1)"
+	case reflect.Int8:
+		return "tinyint"
+	case reflect.Int16:
+		return "smallint"
+	case reflect.Int, reflect.Int32:
+		return "int"
+	case reflect.Int64:
+		return "bigint"
+	case reflect.Uint8:
+		return "tinyint unsigned"
+	case reflect.Uint16:
+		return "smallint unsigned"
+	case reflect.Uint, reflect.Uint32:
+		return "int unsigned"
+	case reflect.Uint64:
+		return "bigint unsigned"
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "varchar(255)"
+	case reflect.Array, reflect.Slice:
+		return "longblob"
+	case reflect.Struct:
+		if _, ok := typ.Interface().(time.Time); ok {
+			return "datetime"
+		}
+	}
+
+	panic(fmt.Sprintf("invalid sql type %s (%s) ", typ.Type().Name(), typ.Kind()))
+}
+
+// TableExistSql 生成表是否存在的sql语句
+// 只在当前连接的数据库下查找
+func (m *mysql) TableExistSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", args
+}
+
+// BindVar mysql的占位符都是 ?
+func (m *mysql) BindVar(index int) string {
+	return "?"
+}
+
+// Quote mysql使用反引号包裹标识符
+func (m *mysql) Quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// ColumnSql mysql的字符串默认是varchar(255)，可以用size修改长度
+func (m *mysql) ColumnSql(c *Column) string {
+	typ := c.Type
+	if typ == "varchar(255)" && c.Size > 0 {
+		typ = fmt.Sprintf("varchar(%d)", c.Size)
+	}
+	if c.AutoIncrement {
+		typ += " AUTO_INCREMENT"
+	}
+	return typ + constraints(c)
+}
+
+// ColumnsSql 从information_schema查询已有的列
+func (m *mysql) ColumnsSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT column_name, column_type FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?", args
+}
+
+// IndexesSql 从information_schema查询已有的索引，联合索引会有多行所以需要去重
+func (m *mysql) IndexesSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ?", args
+}
+
+// ConflictSql mysql使用 ON DUPLICATE KEY UPDATE 处理冲突，插入的值通过 VALUES(col) 引用
+// mysql没有 DO NOTHING，把主键更新为自己来忽略插入的行，没有主键时使用其他的列
+// 只有在没有任何列可用时才返回空字符串，这时插入语句本身也没有列
+func (m *mysql) ConflictSql(c *Conflict) (string, error) {
+	var sets []string
+	if c.DoNothing || len(c.Updates) == 0 {
+		columns := append([]string{c.Primary}, append(c.Columns, c.Updates...)...)
+		for _, name := range columns {
+			if name != "" {
+				sets = append(sets, m.Quote(name)+" = "+m.Quote(name))
+				break
+			}
+		}
+	} else {
+		for _, name := range c.Updates {
+			sets = append(sets, m.Quote(name)+" = VALUES("+m.Quote(name)+")")
+		}
+	}
+	if len(sets) == 0 {
+		return "", nil
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil
+}
+
+// GeneratedKeys mysql不支持RETURNING，多行插入时LastInsertId是第一行的主键
+// innodb_autoinc_lock_mode为2(mysql 8的默认值)时，并发插入可能让一条语句的自增值不连续，这时写回的主键可能是错的
+func (m *mysql) GeneratedKeys() GeneratedKeys {
+	return FirstInsertID
+}
+
+// SavepointSql 创建保存点
+func (m *mysql) SavepointSql(name string) string {
+	return savepoint(m, name)
+}
+
+// RollbackToSql 回滚到保存点，保存点之后的修改被撤销，事务继续
+func (m *mysql) RollbackToSql(name string) string {
+	return rollbackTo(m, name)
+}
+
+// ReleaseSavepointSql 释放保存点，嵌套的事务成功后不再需要它
+func (m *mysql) ReleaseSavepointSql(name string) string {
+	return releaseSavepoint(m, name)
+}
+
+// IsRetryable mysql的死锁是 1213，等待锁超时是 1205
+// 为了不依赖驱动，从错误的Number字段读取错误码，比如 go-sql-driver 的 *mysql.MySQLError
+func (m *mysql) IsRetryable(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		number := v.FieldByName("Number")
+		switch number.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return number.Uint() == 1213 || number.Uint() == 1205
+		}
+	}
+	return false
+}
+
+// BeginTx 驱动支持隔离级别和只读事务
+func (m *mysql) BeginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*sql.Tx, func(error), error) {
+	return beginTx(ctx, db, opts)
+}
@@ -0,0 +1,138 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postgres方言不内置驱动，使用前请自行导入，比如 _ "github.com/lib/pq"
+type postgres struct{}
+
+// 在编译期检测postgres结构体是否实现了Dialect接口
+var _ Dialect = (*postgres)(nil)
+
+func init() {
+	RegisterDialect("postgres", &postgres{})
+}
+
+// DataType将go的数据类型转化为postgres的数据类型
+func (p *postgres) DataType(typ reflect.Value) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "smallint"
+	case reflect.Int, reflect.Int32, reflect.Uint, reflect.Uint16, reflect.Uint32:
+		return "integer"
+	case reflect.Int64, reflect.Uint64:
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double precision"
+	case reflect.String:
+		return "text"
+	case reflect.Array, reflect.Slice:
+		return "bytea"
+	case reflect.Struct:
+		if _, ok := typ.Interface().(time.Time); ok {
+			return "timestamp"
+		}
+	}
+
+	panic(fmt.Sprintf("invalid sql type %s (%s) ", typ.Type().Name(), typ.Kind()))
+}
+
+// TableExistSql 生成表是否存在的sql语句
+// 只在当前schema下查找
+func (p *postgres) TableExistSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?", args
+}
+
+// BindVar postgres的占位符是 $1, $2 ...
+func (p *postgres) BindVar(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+// Quote postgres使用双引号包裹标识符，包裹后大小写敏感
+func (p *postgres) Quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// ColumnSql postgres使用serial系列类型实现自增，有size的字符串使用varchar
+func (p *postgres) ColumnSql(c *Column) string {
+	typ := c.Type
+	switch {
+	case c.AutoIncrement && typ == "bigint":
+		typ = "bigserial"
+	case c.AutoIncrement && typ == "smallint":
+		typ = "smallserial"
+	case c.AutoIncrement:
+		typ = "serial"
+	case typ == "text" && c.Size > 0:
+		typ = fmt.Sprintf("varchar(%d)", c.Size)
+	}
+	return typ + constraints(c)
+}
+
+// ColumnsSql 从information_schema查询已有的列
+// 类型的全称会被转化为建表时使用的简称，方便比较
+func (p *postgres) ColumnsSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT column_name, CASE data_type " +
+		"WHEN 'character varying' THEN 'varchar' " +
+		"WHEN 'timestamp without time zone' THEN 'timestamp' " +
+		"ELSE data_type END " +
+		"FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?", args
+}
+
+// IndexesSql 从pg_indexes查询已有的索引
+func (p *postgres) IndexesSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = ?", args
+}
+
+// ConflictSql postgres使用 ON CONFLICT 处理冲突，DO UPDATE 必须指定冲突的列
+func (p *postgres) ConflictSql(c *Conflict) (string, error) {
+	if !c.DoNothing && len(c.Updates) > 0 && len(c.Columns) == 0 {
+		return "", ErrConflictColumns
+	}
+	return onConflict(p, c), nil
+}
+
+// GeneratedKeys postgres的驱动不支持LastInsertId，总是用RETURNING
+func (p *postgres) GeneratedKeys() GeneratedKeys {
+	return Returning
+}
+
+// SavepointSql 创建保存点
+func (p *postgres) SavepointSql(name string) string {
+	return savepoint(p, name)
+}
+
+// RollbackToSql 回滚到保存点，保存点之后的修改被撤销，事务继续
+func (p *postgres) RollbackToSql(name string) string {
+	return rollbackTo(p, name)
+}
+
+// ReleaseSavepointSql 释放保存点，嵌套的事务成功后不再需要它
+func (p *postgres) ReleaseSavepointSql(name string) string {
+	return releaseSavepoint(p, name)
+}
+
+// IsRetryable postgres的序列化失败是 40001，死锁是 40P01
+func (p *postgres) IsRetryable(err error) bool {
+	state := sqlState(err)
+	return state == "40001" || state == "40P01"
+}
+
+// BeginTx 驱动支持隔离级别和只读事务
+func (p *postgres) BeginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*sql.Tx, func(error), error) {
+	return beginTx(ctx, db, opts)
+}
@@ -1,12 +1,17 @@
 package dialect
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	// _ "github.com/mattn/go-sqlite3" //内置sqlite3
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
 )
 
 type sqlite3 struct{}
@@ -49,3 +54,114 @@ func (s *sqlite3) TableExistSql(tableName string) (string, []interface{}) {
 	args := []interface{}{tableName}
 	return "SELECT name FROM sqlite_master WHERE type = 'table' and name = ?", args
 }
+
+// BindVar sqlite3的占位符都是 ?
+func (s *sqlite3) BindVar(index int) string {
+	return "?"
+}
+
+// Quote sqlite3使用双引号包裹标识符
+func (s *sqlite3) Quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// ColumnSql sqlite3只有 integer PRIMARY KEY 才能自增
+// 字符串的长度sqlite3不会限制，这里忽略size
+func (s *sqlite3) ColumnSql(c *Column) string {
+	if c.AutoIncrement {
+		col := *c
+		col.PrimaryKey = false
+		return "integer PRIMARY KEY AUTOINCREMENT" + constraints(&col)
+	}
+	return c.Type + constraints(c)
+}
+
+// ColumnsSql 通过 PRAGMA table_info 查询已有的列
+func (s *sqlite3) ColumnsSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT name, type FROM pragma_table_info(?)", args
+}
+
+// IndexesSql 从sqlite_master查询已有的索引
+func (s *sqlite3) IndexesSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT name FROM sqlite_master WHERE type = 'index' and tbl_name = ?", args
+}
+
+// ConflictSql sqlite使用 ON CONFLICT 处理冲突
+func (s *sqlite3) ConflictSql(c *Conflict) (string, error) {
+	return onConflict(s, c), nil
+}
+
+// GeneratedKeys sqlite用LastInsertId取得生成的主键，RETURNING返回的行顺序不确定，多行插入时逐行插入
+func (s *sqlite3) GeneratedKeys() GeneratedKeys {
+	return LastInsertID
+}
+
+// SavepointSql 创建保存点
+func (s *sqlite3) SavepointSql(name string) string {
+	return savepoint(s, name)
+}
+
+// RollbackToSql 回滚到保存点，保存点之后的修改被撤销，事务继续
+func (s *sqlite3) RollbackToSql(name string) string {
+	return rollbackTo(s, name)
+}
+
+// ReleaseSavepointSql 释放保存点，嵌套的事务成功后不再需要它
+func (s *sqlite3) ReleaseSavepointSql(name string) string {
+	return releaseSavepoint(s, name)
+}
+
+// IsRetryable sqlite在其他连接持有锁时返回 SQLITE_BUSY(5) 或 SQLITE_LOCKED(6)，扩展错误码的低8位是主错误码
+func (s *sqlite3) IsRetryable(err error) bool {
+	var e *sqlite.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	code := e.Code() & 0xff
+	return code == 5 || code == 6
+}
+
+// BeginTx 驱动提交时遇到SQLITE_BUSY不会结束事务，连接回到连接池后会一直持有锁，
+// 所以事务独占一个连接，提交或者回滚失败时丢弃这个连接，关闭连接时sqlite会回滚事务
+// 驱动会忽略事务选项：只读事务在连接上设置 PRAGMA query_only；sqlite的事务总是可串行化的，
+// 满足 sql.LevelSerializable 及以下的隔离级别，更高的级别返回错误
+func (s *sqlite3) BeginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*sql.Tx, func(error), error) {
+	readOnly := false
+	if opts != nil {
+		if opts.Isolation > sql.LevelSerializable {
+			return nil, nil, fmt.Errorf("sqlite does not support isolation level %s", opts.Isolation)
+		}
+		readOnly = opts.ReadOnly
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if readOnly {
+		if _, err = conn.ExecContext(ctx, "PRAGMA query_only = 1"); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+	}
+	end := func(err error) {
+		// ctx可能已经结束，恢复连接不受它控制
+		if err == nil && readOnly {
+			_, err = conn.ExecContext(context.Background(), "PRAGMA query_only = 0")
+		}
+		if err != nil {
+			// Raw返回driver.ErrBadConn时连接不会回到连接池
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}
+
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		end(nil)
+		return nil, nil, err
+	}
+	return tx, end, nil
+}
@@ -10,18 +10,25 @@ package borm
 import (
 	"database/sql"
 	"errors"
+	"sync"
 
 	"github.com/tomygin/borm/dialect"
 	"github.com/tomygin/borm/log"
+	"github.com/tomygin/borm/schema"
 	"github.com/tomygin/borm/session"
 )
 
 // Eingie是引擎对象
 // db用于调用go的database/sql连接后的对象
 // dialect用于对不同的数据库的类型适配为go的数据类型
+// schemas缓存解析过的结构体，同时决定结构体和字段在数据库中的名字
+// callbacks是所有Session增删查改时执行的回调
 type Engine struct {
-	db      *sql.DB
-	dialect dialect.Dialect
+	db        *sql.DB
+	dialect   dialect.Dialect
+	mu        sync.RWMutex //保护schemas，SetNamingStrategy可能和NewSession并发调用
+	schemas   *schema.Cache
+	callbacks *session.Callbacks
 }
 
 // NewEngine用于生成一个Engine实例
@@ -59,7 +66,7 @@ func NewEngine(info ...string) (e *Engine, err error) {
 		return
 	}
 
-	e = &Engine{db: db, dialect: dial}
+	e = &Engine{db: db, dialect: dial, schemas: schema.NewCache(nil), callbacks: session.NewCallbacks()}
 
 	log.Infof("Connect %s success \n", source)
 	return
@@ -72,7 +79,32 @@ func (e *Engine) Close() {
 	log.Info("Close database success ")
 }
 
+// SetNamingStrategy修改命名策略，只对之后生成的Session有效，可以和NewSession并发调用
+// 比如添加表前缀 e.SetNamingStrategy(schema.SnakeNaming{TablePrefix: "t_"})
+// 已经缓存的Schema会被丢弃
+func (e *Engine) SetNamingStrategy(namer schema.NamingStrategy) {
+	cache := schema.NewCache(namer)
+	e.mu.Lock()
+	e.schemas = cache
+	e.mu.Unlock()
+}
+
 func (e *Engine) NewSession() *session.Session {
-	s := session.New(e.db, e.dialect)
+	e.mu.RLock()
+	schemas := e.schemas
+	e.mu.RUnlock()
+	s := session.New(e.db, e.dialect, session.WithSchemaCache(schemas), session.WithCallbacks(e.callbacks))
 	return s
 }
+
+// Callback返回回调的注册表，可以在内置的增删查改前后添加回调，比如
+// e.Callback().Create().Before("borm:insert").Register("audit", fn)
+// 内置的回调有 borm:before_insert、borm:insert、borm:after_insert，query、update、delete 同理
+func (e *Engine) Callback() *session.Callbacks {
+	return e.callbacks
+}
+
+// AutoMigrate根据结构体同步表结构，详见session.AutoMigrate
+func (e *Engine) AutoMigrate(values ...interface{}) error {
+	return e.NewSession().AutoMigrate(values...)
+}
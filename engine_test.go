@@ -0,0 +1,175 @@
+package borm
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomygin/borm/log"
+	"github.com/tomygin/borm/schema"
+	"github.com/tomygin/borm/session"
+)
+
+// newTestEngine生成一个使用临时sqlite数据库的Engine
+func newTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	log.SetLevel(log.ErrorLevel)
+	e, err := NewEngine("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+// 用 go test -race 运行时检查SetNamingStrategy和NewSession之间没有数据竞争
+func TestSetNamingStrategyConcurrent(t *testing.T) {
+	e := newTestEngine(t)
+
+	type UserInfo struct{ Name string }
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			e.SetNamingStrategy(schema.SnakeNaming{TablePrefix: "t_"})
+		}()
+		go func() {
+			defer wg.Done()
+			e.NewSession().Model(&UserInfo{}).RefTable()
+		}()
+	}
+	wg.Wait()
+
+	if name := e.NewSession().Model(&UserInfo{}).RefTable().Name; name != "t_user_infos" {
+		t.Errorf("table name = %q, want t_user_infos", name)
+	}
+}
+
+type txUser struct {
+	Name string `borm:"primaryKey"`
+}
+
+func TestTransactionRecoversPanic(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.NewSession().Model(&txUser{}).CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := e.Transaction(func(s *session.Session) (interface{}, error) {
+		if _, err := s.Insert(&txUser{Name: "a"}); err != nil {
+			return nil, err
+		}
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("err = %v, want the panic as an error", err)
+	}
+	if count, _ := e.NewSession().Model(&txUser{}).Count(); count != 0 {
+		t.Errorf("count = %d, want 0 after rollback", count)
+	}
+}
+
+func TestTransactionWithReadOnly(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.NewSession().Model(&txUser{}).CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	// 只用一个连接，确认只读事务结束后连接恢复可写
+	e.db.SetMaxOpenConns(1)
+
+	opts := &TxOptions{ReadOnly: true, Isolation: sql.LevelSerializable}
+	_, err := e.TransactionWith(opts, func(s *session.Session) (interface{}, error) {
+		return s.Insert(&txUser{Name: "a"})
+	})
+	if err == nil {
+		t.Error("insert in a read-only transaction should fail")
+	}
+	if _, err := e.TransactionWith(opts, func(s *session.Session) (interface{}, error) {
+		return s.Model(&txUser{}).Count()
+	}); err != nil {
+		t.Errorf("read in a read-only transaction: %v", err)
+	}
+
+	if _, err := e.NewSession().Insert(&txUser{Name: "b"}); err != nil {
+		t.Errorf("insert after a read-only transaction: %v", err)
+	}
+	if count, _ := e.NewSession().Model(&txUser{}).Count(); count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+}
+
+func TestTransactionWithUnsupportedIsolation(t *testing.T) {
+	e := newTestEngine(t)
+	called := false
+	_, err := e.TransactionWith(&TxOptions{Isolation: sql.LevelLinearizable}, func(s *session.Session) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	if err == nil || called {
+		t.Errorf("err = %v, called = %v, want an error before f is called", err, called)
+	}
+}
+
+func TestTransactionWithRetryOnBusy(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.NewSession().Model(&txUser{}).CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &TxOptions{Retry: RetryPolicy{MaxRetries: 100, Backoff: time.Millisecond, MaxBackoff: 20 * time.Millisecond}}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 先读后写，多个事务同时升级为写锁时会遇到SQLITE_BUSY
+			_, err := e.TransactionWith(opts, func(s *session.Session) (interface{}, error) {
+				if _, err := s.Model(&txUser{}).Count(); err != nil {
+					return nil, err
+				}
+				time.Sleep(5 * time.Millisecond)
+				return s.Insert(&txUser{Name: fmt.Sprint(i)})
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if count, _ := e.NewSession().Model(&txUser{}).Count(); count != 8 {
+		t.Errorf("count = %d, want 8", count)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{RetryPolicy{}, 0, 10 * time.Millisecond},
+		{RetryPolicy{}, 2, 40 * time.Millisecond},
+		{RetryPolicy{Backoff: time.Millisecond}, 3, 8 * time.Millisecond},
+		{RetryPolicy{Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, 3, 5 * time.Millisecond},
+		{RetryPolicy{Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, 1000, 5 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.delay(tt.attempt); got != tt.want {
+			t.Errorf("%+v.delay(%d) = %v, want %v", tt.policy, tt.attempt, got, tt.want)
+		}
+	}
+	// 没有上限时多次翻倍也不会溢出
+	if got := (RetryPolicy{}).delay(1000); got <= 0 {
+		t.Errorf("delay(1000) = %v, want a positive duration", got)
+	}
+}
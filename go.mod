@@ -2,11 +2,13 @@ module github.com/tomygin/borm
 
 go 1.17
 
-require modernc.org/sqlite v1.22.1
+require (
+	github.com/google/uuid v1.3.0
+	modernc.org/sqlite v1.22.1
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
 	github.com/mattn/go-isatty v0.0.16 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
@@ -0,0 +1,292 @@
+// Package migrate 实现按版本执行的数据库迁移
+//
+// 每个迁移都有up和down两个方向，已经执行的版本记录在 borm_migrations 表中
+// 每个迁移都在一个单独的事务中执行，执行失败会回滚
+// 注意mysql中的DDL语句会隐式提交事务，失败时无法回滚
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomygin/borm"
+	"github.com/tomygin/borm/log"
+	"github.com/tomygin/borm/session"
+)
+
+// Func是用go实现的迁移步骤
+type Func func(s *session.Session) error
+
+// Migration是一个版本的迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      Func
+	Down    Func // 为nil时该迁移不能回滚
+
+	checksum string
+}
+
+// Record是迁移记录表中的一行
+type Record struct {
+	Version   int64 `borm:"primaryKey"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (r *Record) TableName() string {
+	return "borm_migrations"
+}
+
+// Status是一个版本的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator负责注册和执行迁移
+type Migrator struct {
+	engine     *borm.Engine
+	migrations []*Migration // 按版本从小到大排序
+}
+
+// New生成一个在engine上执行迁移的Migrator
+func New(engine *borm.Engine) *Migrator {
+	return &Migrator{engine: engine}
+}
+
+// Register注册一个用go实现的迁移
+// go代码无法计算校验和，所以只有名字变化时才会被认为迁移被修改了
+func (m *Migrator) Register(version int64, name string, up, down Func) error {
+	return m.add(&Migration{Version: version, Name: name, Up: up, Down: down, checksum: checksum(name)})
+}
+
+// RegisterSQL注册一个用sql实现的迁移，down为空时该迁移不能回滚
+func (m *Migrator) RegisterSQL(version int64, name, up, down string) error {
+	mg := &Migration{Version: version, Name: name, Up: execSql(up), checksum: checksum(up, down)}
+	if down != "" {
+		mg.Down = execSql(down)
+	}
+	return m.add(mg)
+}
+
+// LoadFS注册fsys根目录下所有的sql迁移文件
+// 文件名的格式为 版本_名字.up.sql 和 版本_名字.down.sql，比如 0001_create_users.up.sql
+// 可以配合 os.DirFS 和 embed.FS 使用
+func (m *Migrator) LoadFS(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		base := strings.TrimSuffix(file, ".up.sql")
+		i := strings.Index(base, "_")
+		if i < 0 {
+			return fmt.Errorf("migrate: invalid file name %s", file)
+		}
+		version, err := strconv.ParseInt(base[:i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate: invalid version of file %s", file)
+		}
+
+		up, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		down, err := fs.ReadFile(fsys, base+".down.sql")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := m.RegisterSQL(version, base[i+1:], string(up), string(down)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) add(mg *Migration) error {
+	for _, exist := range m.migrations {
+		if exist.Version == mg.Version {
+			return fmt.Errorf("migrate: duplicate version %d", mg.Version)
+		}
+	}
+	m.migrations = append(m.migrations, mg)
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return nil
+}
+
+// Up按版本顺序执行所有还没执行的迁移
+func (m *Migrator) Up() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+		if err := m.up(mg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down按版本从大到小回滚最近执行的n个迁移
+func (m *Migrator) Down(n int) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	for i := 0; i < n && i < len(versions); i++ {
+		mg := m.find(versions[i])
+		if mg == nil {
+			return fmt.Errorf("migrate: version %d is applied but not registered", versions[i])
+		}
+		if err := m.down(mg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redo回滚最近执行的一个迁移，然后重新执行它
+func (m *Migrator) Redo() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	var last *Migration
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; ok {
+			last = mg
+		}
+	}
+	if last == nil {
+		return nil
+	}
+	if err := m.down(last); err != nil {
+		return err
+	}
+	return m.up(last)
+}
+
+// Status返回所有迁移的执行状态，包括已经执行但没有注册的版本
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var status []Status
+	for _, mg := range m.migrations {
+		st := Status{Version: mg.Version, Name: mg.Name}
+		if record, ok := applied[mg.Version]; ok {
+			st.Applied, st.AppliedAt = true, record.AppliedAt
+			delete(applied, mg.Version)
+		}
+		status = append(status, st)
+	}
+	for _, record := range applied {
+		status = append(status, Status{Version: record.Version, Name: record.Name, Applied: true, AppliedAt: record.AppliedAt})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+// applied查询已经执行的迁移，同时检查它们的校验和有没有变化
+func (m *Migrator) applied() (map[int64]Record, error) {
+	s := m.engine.NewSession()
+	if err := s.AutoMigrate(&Record{}); err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := s.Model(&Record{}).Find(&records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]Record, len(records))
+	for _, record := range records {
+		if mg := m.find(record.Version); mg != nil && mg.checksum != record.Checksum {
+			return nil, fmt.Errorf("migrate: checksum of applied version %d (%s) changed", record.Version, record.Name)
+		}
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg
+		}
+	}
+	return nil
+}
+
+// up在事务中执行迁移并记录版本
+func (m *Migrator) up(mg *Migration) error {
+	_, err := m.engine.Transaction(func(s *session.Session) (interface{}, error) {
+		if err := mg.Up(s); err != nil {
+			return nil, fmt.Errorf("migrate: up %d (%s): %w", mg.Version, mg.Name, err)
+		}
+		record := &Record{Version: mg.Version, Name: mg.Name, Checksum: mg.checksum, AppliedAt: time.Now()}
+		_, err := s.Model(record).Insert(record)
+		return nil, err
+	})
+	return err
+}
+
+// down在事务中回滚迁移并删除版本记录
+func (m *Migrator) down(mg *Migration) error {
+	if mg.Down == nil {
+		return fmt.Errorf("migrate: version %d (%s) is irreversible", mg.Version, mg.Name)
+	}
+	_, err := m.engine.Transaction(func(s *session.Session) (interface{}, error) {
+		if err := mg.Down(s); err != nil {
+			return nil, fmt.Errorf("migrate: down %d (%s): %w", mg.Version, mg.Name, err)
+		}
+		_, err := s.Model(&Record{}).Where("version = ?", mg.Version).Delete()
+		return nil, err
+	})
+	return err
+}
+
+// execSql将sql语句包装为迁移步骤
+// sql直接在连接上执行，不会改写占位符，postgres的jsonb操作符 ?、?| 和 ?& 可以原样使用
+func execSql(sql string) Func {
+	return func(s *session.Session) error {
+		log.Info(sql)
+		_, err := s.DB().ExecContext(s.Context(), sql)
+		if err != nil {
+			log.Error(err)
+		}
+		return err
+	}
+}
+
+func checksum(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,186 @@
+package migrate
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tomygin/borm"
+	"github.com/tomygin/borm/log"
+	"github.com/tomygin/borm/session"
+)
+
+// newTestMigrator生成一个使用临时sqlite数据库的Migrator，注册了三个迁移
+func newTestMigrator(t *testing.T) (*Migrator, *borm.Engine) {
+	t.Helper()
+	log.SetLevel(log.ErrorLevel)
+	e, err := borm.NewEngine("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+
+	m := New(e)
+	err = m.LoadFS(fstest.MapFS{
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id integer PRIMARY KEY, name text);")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"0002_add_age.up.sql":        {Data: []byte("ALTER TABLE users ADD COLUMN age integer;")},
+		"0002_add_age.down.sql":      {Data: []byte("ALTER TABLE users DROP COLUMN age;")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Register(3, "seed_users", func(s *session.Session) error {
+		_, err := s.Raw("INSERT INTO users (id, name, age) VALUES (?, ?, ?)", 1, "a", 18).Exec()
+		return err
+	}, func(s *session.Session) error {
+		_, err := s.Raw("DELETE FROM users").Exec()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, e
+}
+
+// appliedVersions返回Status中已经执行的版本
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	for _, st := range status {
+		if st.Applied {
+			versions = append(versions, st.Version)
+		}
+	}
+	return versions
+}
+
+// countUsers返回users表的行数
+func countUsers(t *testing.T, e *borm.Engine) int {
+	t.Helper()
+	var n int
+	if err := e.NewSession().Raw("SELECT count(*) FROM users").QueryRow().Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestUpDown(t *testing.T) {
+	m, e := newTestMigrator(t)
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := appliedVersions(t, m), []int64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied = %v, want %v", got, want)
+	}
+	if n := countUsers(t, e); n != 1 {
+		t.Errorf("users = %d, want 1", n)
+	}
+
+	if err := m.Down(2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := appliedVersions(t, m), []int64{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied = %v, want %v", got, want)
+	}
+	if _, err := e.NewSession().Raw("SELECT age FROM users").Exec(); err == nil {
+		t.Error("column age should be dropped")
+	}
+
+	// 再次执行Up只会执行回滚了的迁移
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := appliedVersions(t, m), []int64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied = %v, want %v", got, want)
+	}
+}
+
+func TestRedo(t *testing.T) {
+	m, e := newTestMigrator(t)
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.NewSession().Raw("INSERT INTO users (id, name) VALUES (2, 'b')").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回滚seed_users会删除所有用户，重新执行后只剩下它插入的一行
+	if err := m.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countUsers(t, e); n != 1 {
+		t.Errorf("users = %d, want 1", n)
+	}
+	if got, want := appliedVersions(t, m), []int64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied = %v, want %v", got, want)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	m, _ := newTestMigrator(t)
+	if err := m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 3 {
+		t.Fatalf("status = %v, want 3 versions", status)
+	}
+	for _, st := range status {
+		if st.Applied {
+			t.Errorf("version %d should not be applied", st.Version)
+		}
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// 已经执行但没有注册的版本也会出现在状态中
+	other := New(m.engine)
+	status, err = other.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, st := range status {
+		if !st.Applied || st.AppliedAt.IsZero() {
+			t.Errorf("version %d should be applied", st.Version)
+		}
+		names = append(names, st.Name)
+	}
+	if want := []string{"create_users", "add_age", "seed_users"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestChangedChecksum(t *testing.T) {
+	m, e := newTestMigrator(t)
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := New(e)
+	if err := changed.RegisterSQL(1, "create_users", "CREATE TABLE users (id integer PRIMARY KEY);", ""); err != nil {
+		t.Fatal(err)
+	}
+	for name, run := range map[string]func() error{
+		"up":     changed.Up,
+		"down":   func() error { return changed.Down(1) },
+		"redo":   changed.Redo,
+		"status": func() error { _, err := changed.Status(); return err },
+	} {
+		if err := run(); err == nil || !strings.Contains(err.Error(), "checksum") {
+			t.Errorf("%s: err = %v, want a checksum error", name, err)
+		}
+	}
+}
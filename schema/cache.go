@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/tomygin/borm/dialect"
+)
+
+// Cache保存已经解析过的Schema，可以被多个Session并发使用
+// 同一个结构体在同一个方言下只会被解析一次
+type Cache struct {
+	namer   NamingStrategy
+	schemas sync.Map // cacheKey -> *Schema
+}
+
+type cacheKey struct {
+	typ     reflect.Type
+	dialect dialect.Dialect
+}
+
+// NewCache生成一个使用命名策略namer的Cache，namer为nil时使用SnakeNaming
+func NewCache(namer NamingStrategy) *Cache {
+	if namer == nil {
+		namer = SnakeNaming{}
+	}
+	return &Cache{namer: namer}
+}
+
+// Namer返回Cache使用的命名策略
+func (c *Cache) Namer() NamingStrategy {
+	return c.namer
+}
+
+// Parse和包级别的Parse一样，但解析结果会被缓存
+// 缓存的Schema中Model是一个指向零值的指针，而不是dest
+func (c *Cache) Parse(dest interface{}, d dialect.Dialect) *Schema {
+	key := cacheKey{typ: reflect.Indirect(reflect.ValueOf(dest)).Type(), dialect: d}
+	if v, ok := c.schemas.Load(key); ok {
+		return v.(*Schema)
+	}
+
+	schema := Parse(reflect.New(key.typ).Interface(), d, c.namer)
+	v, _ := c.schemas.LoadOrStore(key, schema)
+	return v.(*Schema)
+}
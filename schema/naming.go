@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy决定结构体和字段在数据库中的名字
+type NamingStrategy interface {
+	// TableName根据结构体名生成表名
+	TableName(table string) string
+	// ColumnName根据表名和字段名生成列名
+	ColumnName(table, column string) string
+	// IndexName根据表名和列名生成索引名
+	IndexName(table, column string) string
+	// JoinTableName根据many2many中声明的名字生成中间表名
+	JoinTableName(joinTable string) string
+}
+
+// Tabler可以由模型实现，用于直接指定表名，不受命名策略和表前缀的影响
+type Tabler interface {
+	TableName() string
+}
+
+// SnakeNaming是默认的命名策略
+// 表名是复数形式的蛇形命名，比如 UserInfo 为 user_infos
+// 列名是蛇形命名，比如 CreatedAt 为 created_at
+type SnakeNaming struct {
+	TablePrefix   string // 表名前缀，中间表也会添加
+	SingularTable bool   // 表名不使用复数形式
+}
+
+var _ NamingStrategy = SnakeNaming{}
+
+func (n SnakeNaming) TableName(table string) string {
+	if n.SingularTable {
+		return n.TablePrefix + toSnake(table)
+	}
+	return n.TablePrefix + pluralize(toSnake(table))
+}
+
+func (n SnakeNaming) ColumnName(table, column string) string {
+	return toSnake(column)
+}
+
+func (n SnakeNaming) IndexName(table, column string) string {
+	return strings.ReplaceAll("idx_"+table+"_"+column, ".", "_")
+}
+
+func (n SnakeNaming) JoinTableName(joinTable string) string {
+	return n.TablePrefix + toSnake(joinTable)
+}
+
+// toSnake将驼峰命名转化为蛇形命名，连续的大写字母视为一个单词
+// 比如 UserID 为 user_id，HTTPServer 为 http_server
+func toSnake(name string) string {
+	runes := []rune(name)
+	var snake strings.Builder
+	for i, r := range runes {
+		if !unicode.IsUpper(r) {
+			snake.WriteRune(r)
+			continue
+		}
+		if i > 0 && runes[i-1] != '_' {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				snake.WriteByte('_')
+			}
+		}
+		snake.WriteRune(unicode.ToLower(r))
+	}
+	return snake.String()
+}
+
+// 不规则的复数形式
+var irregulars = map[string]string{
+	"person": "people",
+	"man":    "men",
+	"woman":  "women",
+	"child":  "children",
+	"mouse":  "mice",
+	"foot":   "feet",
+	"tooth":  "teeth",
+	"goose":  "geese",
+}
+
+// 单复数形式一样的单词
+var uncountables = map[string]bool{
+	"sheep":       true,
+	"fish":        true,
+	"series":      true,
+	"species":     true,
+	"news":        true,
+	"information": true,
+	"equipment":   true,
+}
+
+// pluralize将蛇形命名的最后一个单词转化为复数形式
+func pluralize(name string) string {
+	prefix, word := "", name
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		prefix, word = name[:i+1], name[i+1:]
+	}
+
+	if uncountables[word] || word == "" {
+		return name
+	}
+	if p, ok := irregulars[word]; ok {
+		return prefix + p
+	}
+
+	switch {
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"), strings.HasSuffix(word, "z"),
+		strings.HasSuffix(word, "ch"), strings.HasSuffix(word, "sh"):
+		word += "es"
+	case strings.HasSuffix(word, "y") && len(word) > 1 && !strings.ContainsAny(word[len(word)-2:len(word)-1], "aeiou"):
+		word = word[:len(word)-1] + "ies"
+	case strings.HasSuffix(word, "fe"):
+		word = word[:len(word)-2] + "ves"
+	default:
+		word += "s"
+	}
+	return prefix + word
+}
@@ -0,0 +1,66 @@
+package schema
+
+import "testing"
+
+func TestToSnake(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"", ""},
+		{"name", "name"},
+		{"Name", "name"},
+		{"UserInfo", "user_info"},
+		{"CreatedAt", "created_at"},
+		{"UserID", "user_id"},
+		{"ID", "id"},
+		{"HTTPServer", "http_server"},
+		{"APIKeyV2", "api_key_v2"},
+		{"Address2Line", "address2_line"},
+		{"user_Name", "user_name"},
+	}
+	for _, tt := range tests {
+		if got := toSnake(tt.name); got != tt.want {
+			t.Errorf("toSnake(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"user", "users"},
+		{"user_info", "user_infos"},
+		{"address", "addresses"},
+		{"box", "boxes"},
+		{"match", "matches"},
+		{"dish", "dishes"},
+		{"category", "categories"},
+		{"day", "days"},
+		{"knife", "knives"},
+		{"person", "people"},
+		{"user_child", "user_children"},
+		{"sheep", "sheep"},
+		{"user_news", "user_news"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := pluralize(tt.name); got != tt.want {
+			t.Errorf("pluralize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSnakeNaming(t *testing.T) {
+	n := SnakeNaming{TablePrefix: "t_"}
+	if got := n.TableName("UserInfo"); got != "t_user_infos" {
+		t.Errorf("TableName = %q, want t_user_infos", got)
+	}
+	n.SingularTable = true
+	if got := n.TableName("UserInfo"); got != "t_user_info" {
+		t.Errorf("singular TableName = %q, want t_user_info", got)
+	}
+	if got := n.JoinTableName("UserLanguages"); got != "t_user_languages" {
+		t.Errorf("JoinTableName = %q, want t_user_languages", got)
+	}
+}
@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type RelationType string
+
+const (
+	HasOne    RelationType = "has_one"
+	HasMany   RelationType = "has_many"
+	BelongsTo RelationType = "belongs_to"
+	Many2Many RelationType = "many_to_many"
+)
+
+// Relation是模型中的一个关联字段，比如 User{Orders []Order}
+// HasOne和HasMany的外键ForeignKey在关联模型中，引用当前模型的References
+// BelongsTo的外键ForeignKey在当前模型中，引用关联模型的References
+// Many2Many通过中间表关联，中间表的JoinForeignKey列引用当前模型的References，
+// JoinReferences列引用关联模型的主键
+type Relation struct {
+	Name      string       //结构体字段名
+	Type      RelationType //关联的类型
+	Index     []int        //从模型到关联字段的索引路径
+	FieldType reflect.Type //关联字段的类型，比如 []Order、*Profile
+	Schema    *Schema      //关联模型
+
+	ForeignKey *Field
+	References *Field
+
+	JoinTable      string
+	JoinForeignKey string
+	JoinReferences string
+
+	modelType reflect.Type      //关联模型的结构体类型
+	settings  map[string]string //关联字段的tag，解析完所有字段后才能确定外键
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isRelation判断字段的类型是不是关联，结构体、结构体指针以及它们的切片都是关联
+// time.Time和实现了sql.Scanner或者driver.Valuer的结构体是普通的列
+func isRelation(typ reflect.Type) bool {
+	typ = indirectType(typ)
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = indirectType(typ.Elem())
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
+	}
+	ptr := reflect.PtrTo(typ)
+	return !ptr.Implements(scannerType) && !ptr.Implements(valuerType)
+}
+
+// addRelation记录关联字段，外键在resolveRelation中确定
+func (s *Schema) addRelation(p reflect.StructField, index []int, settings map[string]string) {
+	rel := &Relation{
+		Name:      p.Name,
+		Index:     index,
+		FieldType: p.Type,
+		modelType: indirectType(p.Type),
+		settings:  settings,
+	}
+	if kind := rel.modelType.Kind(); kind == reflect.Slice || kind == reflect.Array {
+		rel.modelType = indirectType(rel.modelType.Elem())
+		rel.Type = HasMany
+		if _, ok := settings["many2many"]; ok {
+			rel.Type = Many2Many
+		}
+	}
+	s.Relations = append(s.Relations, rel)
+	s.RelationMap[rel.Name] = rel
+}
+
+// GetRelation根据结构体字段名获取关联
+func (s *Schema) GetRelation(name string) *Relation {
+	return s.RelationMap[name]
+}
+
+// resolveRelation根据tag和字段名确定关联的类型和外键
+// 单个结构体在当前模型中有外键时为BelongsTo，否则为HasOne
+func (s *Schema) resolveRelation(rel *Relation, namer NamingStrategy) error {
+	switch rel.Type {
+	case Many2Many:
+		return s.resolveMany2Many(rel, namer)
+	case HasMany:
+		return s.resolveHas(rel)
+	}
+
+	if fk := rel.settings["foreignkey"]; fk != "" {
+		rel.Type = HasOne
+		if s.GetField(fk) != nil {
+			rel.Type = BelongsTo
+		}
+	} else {
+		rel.Type = HasOne
+		if ref := rel.Schema.PrimaryField; ref != nil && s.GetField(rel.Name+ref.GoName) != nil {
+			rel.Type = BelongsTo
+		}
+	}
+
+	if rel.Type == BelongsTo {
+		return s.resolveBelongsTo(rel)
+	}
+	return s.resolveHas(rel)
+}
+
+// resolveHas确定HasOne和HasMany的外键，默认为关联模型中的 模型名+主键名，比如 UserID
+func (s *Schema) resolveHas(rel *Relation) error {
+	rel.References = s.PrimaryField
+	if ref := rel.settings["references"]; ref != "" {
+		rel.References = s.GetField(ref)
+	}
+	if rel.References == nil {
+		return fmt.Errorf("relation %s.%s: references not found", s.ModelType.Name(), rel.Name)
+	}
+
+	fk := rel.settings["foreignkey"]
+	if fk == "" {
+		fk = s.ModelType.Name() + rel.References.GoName
+	}
+	if rel.ForeignKey = rel.Schema.GetField(fk); rel.ForeignKey == nil {
+		return fmt.Errorf("relation %s.%s: foreign key %s not found in %s", s.ModelType.Name(), rel.Name, fk, rel.Schema.ModelType.Name())
+	}
+	return nil
+}
+
+// resolveBelongsTo确定BelongsTo的外键，默认为当前模型中的 字段名+主键名，比如 ProfileID
+func (s *Schema) resolveBelongsTo(rel *Relation) error {
+	rel.References = rel.Schema.PrimaryField
+	if ref := rel.settings["references"]; ref != "" {
+		rel.References = rel.Schema.GetField(ref)
+	}
+	if rel.References == nil {
+		return fmt.Errorf("relation %s.%s: references not found", s.ModelType.Name(), rel.Name)
+	}
+
+	fk := rel.settings["foreignkey"]
+	if fk == "" {
+		fk = rel.Name + rel.References.GoName
+	}
+	if rel.ForeignKey = s.GetField(fk); rel.ForeignKey == nil {
+		return fmt.Errorf("relation %s.%s: foreign key %s not found", s.ModelType.Name(), rel.Name, fk)
+	}
+	return nil
+}
+
+// resolveMany2Many确定中间表和它的两个外键列
+// 外键列默认为 模型名+主键名 的列名，比如 user_id、language_id
+func (s *Schema) resolveMany2Many(rel *Relation, namer NamingStrategy) error {
+	rel.References = s.PrimaryField
+	if ref := rel.settings["references"]; ref != "" {
+		rel.References = s.GetField(ref)
+	}
+	relPrimary := rel.Schema.PrimaryField
+	if rel.References == nil || relPrimary == nil {
+		return fmt.Errorf("relation %s.%s: many2many needs primary keys on both sides", s.ModelType.Name(), rel.Name)
+	}
+
+	rel.JoinTable = namer.JoinTableName(rel.settings["many2many"])
+	rel.JoinForeignKey = rel.settings["joinforeignkey"]
+	if rel.JoinForeignKey == "" {
+		rel.JoinForeignKey = namer.ColumnName(rel.JoinTable, s.ModelType.Name()+rel.References.GoName)
+	}
+	rel.JoinReferences = rel.settings["joinreferences"]
+	if rel.JoinReferences == "" {
+		rel.JoinReferences = namer.ColumnName(rel.JoinTable, rel.Schema.ModelType.Name()+relPrimary.GoName)
+	}
+	if rel.JoinForeignKey == rel.JoinReferences {
+		return fmt.Errorf("relation %s.%s: join columns are both %s, set joinForeignKey or joinReferences", s.ModelType.Name(), rel.Name, rel.JoinForeignKey)
+	}
+	return nil
+}
+
+// ValueOf从结构体v中读取关联字段的值
+func (r *Relation) ValueOf(v reflect.Value) reflect.Value {
+	return valueByIndex(v, r.Index)
+}
+
+// Settable从结构体v中获取可以写入的关联字段
+func (r *Relation) Settable(v reflect.Value) reflect.Value {
+	return settableByIndex(v, r.Index)
+}
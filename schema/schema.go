@@ -3,69 +3,325 @@ package schema
 import (
 	"go/ast"
 	"reflect"
+	"strings"
 
 	"github.com/tomygin/borm/dialect"
+	"github.com/tomygin/borm/log"
 )
 
 // 数据库里面的字段
+// 列名、类型以及约束都在dialect.Column中，由tag解析得到
 type Field struct {
-	Name string
-	Type string
-	Tag  string
+	dialect.Column
+
+	GoName string       //结构体中的字段名
+	GoType reflect.Type //结构体中的字段类型
+	Tag    string       //原始的borm tag
+	Index  []int        //从模型到字段的索引路径，嵌入的结构体会有多层
+}
+
+// Index是表中的一个索引，多个字段的index标签使用同一个名字时组成联合索引
+type Index struct {
+	Name   string
+	Unique bool
+	Fields []*Field
 }
 
 type Schema struct {
 	Model interface{} //数据库表原型
 	Name  string      //模型名字
 
+	ModelType reflect.Type //模型的结构体类型，不是指针
+
 	Fields     []*Field
 	FieldNames []string //为了加快查找Field
 	FieldMap   map[string]*Field
+	Indexes    []*Index
+
+	PrimaryField *Field //主键，没有声明主键时为名为ID的字段，都没有时为nil
+
+	Relations   []*Relation
+	RelationMap map[string]*Relation //按结构体字段名查找关联
+
+	goFieldMap map[string]*Field //按结构体字段名查找Field
 }
 
+// GetField根据列名获取字段，找不到时再按结构体字段名查找
 func (s *Schema) GetField(name string) *Field {
-	return s.FieldMap[name]
+	if field, ok := s.FieldMap[name]; ok {
+		return field
+	}
+	return s.goFieldMap[name]
 }
 
 // 将任意对象转化为 Schema
 // dest为要转化为Schema的结构体
 // dialect为每个字段提供数据类型转换服务
-func Parse(dest interface{}, d dialect.Dialect) *Schema {
+// namer决定表名和列名，为nil时使用SnakeNaming
+func Parse(dest interface{}, d dialect.Dialect, namer NamingStrategy) *Schema {
+	if namer == nil {
+		namer = SnakeNaming{}
+	}
 	modelType := reflect.Indirect(reflect.ValueOf(dest)).Type()
+	return parse(dest, modelType, d, namer, map[reflect.Type]*Schema{})
+}
+
+// parse解析modelType，parsing记录本次解析过的结构体
+// 关联的模型互相引用时，直接使用parsing中的Schema，避免无限递归
+func parse(dest interface{}, modelType reflect.Type, d dialect.Dialect, namer NamingStrategy, parsing map[reflect.Type]*Schema) *Schema {
+	if schema, ok := parsing[modelType]; ok {
+		return schema
+	}
 
 	schema := &Schema{
-		Model:    dest,
-		Name:     modelType.Name(),
-		FieldMap: map[string]*Field{},
-	}
-	// 将所有的字段都记录在Schema
-	for i := 0; i < modelType.NumField(); i++ {
-		p := modelType.Field(i)
-		if !p.Anonymous && ast.IsExported(p.Name) {
-
-			field := &Field{
-				Name: p.Name,
-				Type: d.DataType(reflect.Indirect(reflect.New(p.Type))),
+		Model:       dest,
+		ModelType:   modelType,
+		Name:        namer.TableName(modelType.Name()),
+		FieldMap:    map[string]*Field{},
+		RelationMap: map[string]*Relation{},
+		goFieldMap:  map[string]*Field{},
+	}
+	parsing[modelType] = schema
+	// TableName方法可能定义在指针上
+	if tabler, ok := reflect.New(modelType).Interface().(Tabler); ok {
+		schema.Name = tabler.TableName()
+	}
+	schema.parseFields(modelType, nil, "", d, namer)
+	schema.PrimaryField = schema.primaryField()
+
+	// 所有的字段都解析完后才能确定关联使用的外键
+	for _, rel := range schema.Relations {
+		rel.Schema = parse(reflect.New(rel.modelType).Interface(), rel.modelType, d, namer, parsing)
+	}
+	var relations []*Relation
+	for _, rel := range schema.Relations {
+		if err := schema.resolveRelation(rel, namer); err != nil {
+			log.Error(err)
+			delete(schema.RelationMap, rel.Name)
+			continue
+		}
+		relations = append(relations, rel)
+	}
+	schema.Relations = relations
+	return schema
+}
+
+// primaryField返回主键字段，没有声明主键时使用名为ID的字段
+func (s *Schema) primaryField() *Field {
+	for _, field := range s.Fields {
+		if field.PrimaryKey {
+			return field
+		}
+	}
+	return s.goFieldMap["ID"]
+}
+
+// parseFields将typ中的字段记录在Schema
+// 匿名嵌入的结构体和带有embedded标签的结构体会被展开，index是从模型到typ的字段索引路径
+// prefix是embeddedPrefix指定的列名前缀
+func (s *Schema) parseFields(typ reflect.Type, index []int, prefix string, d dialect.Dialect, namer NamingStrategy) {
+	for i := 0; i < typ.NumField(); i++ {
+		p := typ.Field(i)
+		tag, hasTag := p.Tag.Lookup("borm")
+		if tag == "-" {
+			continue
+		}
+		settings := parseTag(tag)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		// 展开嵌入的结构体，未导出的嵌入指针无法分配内存，所以跳过
+		if _, embedded := settings["embedded"]; (p.Anonymous || embedded) && isEmbeddable(p) {
+			s.parseFields(indirectType(p.Type), fieldIndex, prefix+settings["embeddedprefix"], d, namer)
+			continue
+		}
+		if p.Anonymous || !ast.IsExported(p.Name) {
+			continue
+		}
+		// 结构体和结构体切片是关联，不作为列
+		if isRelation(p.Type) {
+			s.addRelation(p, fieldIndex, settings)
+			continue
+		}
+
+		field := &Field{
+			Column: dialect.Column{
+				Name: namer.ColumnName(s.Name, p.Name),
+				Type: d.DataType(reflect.New(indirectType(p.Type)).Elem()),
+			},
+			GoName: p.Name,
+			GoType: p.Type,
+			Index:  fieldIndex,
+		}
+		if hasTag {
+			field.Tag = tag
+			parseFieldTag(field, tag)
+		}
+		field.Name = prefix + field.Name
+
+		// 和嵌入结构体中的列重名时，和go一样层级浅的字段优先
+		if exist, ok := s.FieldMap[field.Name]; ok {
+			if len(exist.Index) > len(field.Index) {
+				delete(s.goFieldMap, exist.GoName)
+				*exist = *field
+				s.goFieldMap[exist.GoName] = exist
+			}
+			continue
+		}
+
+		s.Fields = append(s.Fields, field)
+		s.FieldNames = append(s.FieldNames, field.Name)
+		s.FieldMap[field.Name] = field
+		if _, ok := s.goFieldMap[field.GoName]; !ok {
+			s.goFieldMap[field.GoName] = field
+		}
+		s.parseIndexes(field, settings, namer)
+	}
+}
+
+// parseIndexes根据index和uniqueIndex标签记录字段所在的索引，没有指定索引名时由namer生成
+func (s *Schema) parseIndexes(field *Field, settings map[string]string, namer NamingStrategy) {
+	for _, key := range []string{"index", "uniqueindex"} {
+		name, ok := settings[key]
+		if !ok {
+			continue
+		}
+		if name == "" {
+			name = namer.IndexName(s.Name, field.Name)
+		}
+
+		var index *Index
+		for _, idx := range s.Indexes {
+			if idx.Name == name {
+				index = idx
 			}
+		}
+		if index == nil {
+			index = &Index{Name: name, Unique: key == "uniqueindex"}
+			s.Indexes = append(s.Indexes, index)
+		}
+		index.Fields = append(index.Fields, field)
+	}
+}
+
+// isEmbeddable判断字段是否为可以展开的结构体，time.Time被视为普通字段
+func isEmbeddable(p reflect.StructField) bool {
+	typ := indirectType(p.Type)
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
+	}
+	return p.Type.Kind() != reflect.Ptr || ast.IsExported(p.Name)
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// ValueOf从结构体v中读取字段的值，经过的嵌入指针为nil时返回零值
+func (f *Field) ValueOf(v reflect.Value) reflect.Value {
+	return valueByIndex(v, f.Index)
+}
 
-			if v, ok := p.Tag.Lookup("borm"); ok {
-				field.Tag = v
+// Settable从结构体v中获取可以写入的字段，经过的嵌入指针为nil时会分配内存
+func (f *Field) Settable(v reflect.Value) reflect.Value {
+	return settableByIndex(v, f.Index)
+}
+
+func valueByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Zero(v.Type().Elem().FieldByIndex(index[i:]).Type)
 			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
 
-			schema.Fields = append(schema.Fields, field)
-			schema.FieldNames = append(schema.FieldNames, p.Name)
-			schema.FieldMap[p.Name] = field
+func settableByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
 		}
+		v = v.Field(x)
 	}
-	return schema
+	return v
 }
 
 // RecordValues将一个结构体的数据库字段的所有值获取，入参就是这个被获取字段的结构体
 func (s *Schema) RecordValues(dest interface{}) interface{} {
+	return s.ValuesOf(dest, s.Fields)
+}
+
+// ValuesOf按fields的顺序获取dest中对应字段的值
+func (s *Schema) ValuesOf(dest interface{}, fields []*Field) []interface{} {
 	destValue := reflect.Indirect(reflect.ValueOf(dest))
 	var fieldValues []interface{}
-	for _, field := range s.Fields {
-		fieldValues = append(fieldValues, destValue.FieldByName(field.Name).Interface())
+	for _, field := range fields {
+		fieldValues = append(fieldValues, field.ValueOf(destValue).Interface())
 	}
 	return fieldValues
 }
+
+// InsertFields返回插入values时需要写入的字段
+// 自增和有默认值的字段如果在所有values中都是零值，就交给数据库生成
+// 同时有零值和非零值时零值会被原样写入，所以values应该是InsertBatches分出来的一批
+func (s *Schema) InsertFields(values ...interface{}) []*Field {
+	var fields []*Field
+	for _, field := range s.Fields {
+		if (field.AutoIncrement || field.HasDefault) && s.allZero(field, values) {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// InsertBatches将values分成多批，同一批的记录中自增和有默认值的字段要么都是零值要么都不是，
+// 这样交给数据库生成的字段不会在另一条记录中写入零值，相邻的记录才会分在同一批，批之间保持values的顺序
+func (s *Schema) InsertBatches(values []interface{}) [][]interface{} {
+	var batches [][]interface{}
+	var last string
+	for i, value := range values {
+		key := s.generatedKey(value)
+		if i == 0 || key != last {
+			batches = append(batches, nil)
+			last = key
+		}
+		batches[len(batches)-1] = append(batches[len(batches)-1], value)
+	}
+	return batches
+}
+
+// generatedKey记录value中哪些自增和有默认值的字段是零值
+func (s *Schema) generatedKey(value interface{}) string {
+	dest := reflect.Indirect(reflect.ValueOf(value))
+	var key strings.Builder
+	for _, field := range s.Fields {
+		if !field.AutoIncrement && !field.HasDefault {
+			continue
+		}
+		if field.ValueOf(dest).IsZero() {
+			key.WriteByte('0')
+		} else {
+			key.WriteByte('1')
+		}
+	}
+	return key.String()
+}
+
+func (s *Schema) allZero(field *Field, values []interface{}) bool {
+	for _, value := range values {
+		if !field.ValueOf(reflect.Indirect(reflect.ValueOf(value))).IsZero() {
+			return false
+		}
+	}
+	return true
+}
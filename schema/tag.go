@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tomygin/borm/log"
+)
+
+// parseTag将 borm tag 解析为键值对，每一项用 ; 分隔，键和值用 : 分隔
+// 比如 column:user_name;primaryKey;size:64
+// 键不区分大小写，并且忽略其中的空格和下划线，所以 PRIMARY KEY 和 primaryKey 是一样的
+func parseTag(tag string) map[string]string {
+	settings := map[string]string{}
+	for _, item := range strings.Split(tag, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		kv := strings.SplitN(item, ":", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		key = strings.NewReplacer(" ", "", "_", "").Replace(key)
+		if len(kv) == 2 {
+			settings[key] = strings.TrimSpace(kv[1])
+		} else {
+			settings[key] = ""
+		}
+	}
+	return settings
+}
+
+// parseFieldTag将tag中的设置写入field
+func parseFieldTag(field *Field, tag string) {
+	for key, value := range parseTag(tag) {
+		switch key {
+		case "column":
+			field.Name = value
+		case "primarykey":
+			field.PrimaryKey = true
+		case "autoincrement":
+			field.AutoIncrement = true
+		case "unique":
+			field.Unique = true
+		case "notnull":
+			field.NotNull = true
+		case "default":
+			field.HasDefault = true
+			field.Default = value
+		case "size":
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				log.Errorf("invalid size %q of field %s\n", value, field.GoName)
+				continue
+			}
+			field.Size = size
+		case "embedded", "embeddedprefix", "index", "uniqueindex":
+			// 在parseFields中处理
+		case "foreignkey", "references", "many2many", "joinforeignkey", "joinreferences":
+			log.Errorf("tag %q of field %s only works on relations\n", key, field.GoName)
+		default:
+			log.Errorf("unknown tag %q of field %s\n", key, field.GoName)
+		}
+	}
+}
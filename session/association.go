@@ -0,0 +1,425 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/tomygin/borm/clause"
+	"github.com/tomygin/borm/schema"
+)
+
+// Association用于修改一个对象的关联，比如
+// s.Model(&user).Association("Orders").Append(&order)
+// 关联的写入都在Session所在的事务中执行
+type Association struct {
+	s     *Session
+	owner reflect.Value  //Model传入的结构体
+	table *schema.Schema //Model传入的结构体的Schema
+	rel   *schema.Relation
+	err   error
+}
+
+// Association返回Model传入的对象上名为name的关联，Model必须传入结构体指针
+func (s *Session) Association(name string) *Association {
+	a := &Association{s: s}
+	value := reflect.ValueOf(s.model)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		a.err = errors.New("association: Model must be a pointer to struct")
+		return a
+	}
+	a.owner, a.table = value.Elem(), s.RefTable()
+	if a.rel = a.table.GetRelation(name); a.rel == nil {
+		a.err = fmt.Errorf("association: %s has no relation %s", a.table.ModelType.Name(), name)
+	} else if a.rel.Type != schema.BelongsTo && a.rel.Schema.PrimaryField == nil {
+		a.err = fmt.Errorf("association: %s has no primary key", a.rel.Schema.ModelType.Name())
+	}
+	return a
+}
+
+// Append添加关联，主键为零值的对象会先被插入
+// HasOne和HasMany会修改关联对象的外键，BelongsTo会修改当前对象的外键，Many2Many会写入中间表
+func (a *Association) Append(values ...interface{}) error {
+	if err := a.checkOwner(); err != nil {
+		return err
+	}
+	targets, err := a.targets(values)
+	if err != nil {
+		return err
+	}
+
+	switch a.rel.Type {
+	case schema.HasOne:
+		// 一对一只能有一个关联，之前的关联会被移除
+		if err = a.appendHas(targets); err == nil {
+			err = a.clear(targets)
+		}
+	case schema.HasMany:
+		err = a.appendHas(targets)
+	case schema.BelongsTo:
+		err = a.appendBelongsTo(targets)
+	case schema.Many2Many:
+		err = a.appendMany2Many(targets)
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.rel.Type == schema.HasMany || a.rel.Type == schema.Many2Many {
+		// 已经在关联字段中的对象会被新的对象替换
+		appended := map[string]bool{}
+		for _, target := range targets {
+			appended[keyOf(a.targetKey(target))] = true
+		}
+		var values []reflect.Value
+		for _, v := range a.loaded() {
+			if !appended[keyOf(a.targetKey(v))] {
+				values = append(values, v)
+			}
+		}
+		targets = append(values, targets...)
+	}
+	setRelation(a.rel, a.owner, targets)
+	return nil
+}
+
+// Replace用values替换当前所有的关联，不在values中的关联会被移除，但不会删除关联的对象
+func (a *Association) Replace(values ...interface{}) error {
+	if err := a.checkOwner(); err != nil {
+		return err
+	}
+	targets, err := a.targets(values)
+	if err != nil {
+		return err
+	}
+	if err := a.clear(targets); err != nil {
+		return err
+	}
+	setRelation(a.rel, a.owner, nil)
+	return a.Append(values...)
+}
+
+// Delete移除values和当前对象的关联，但不会删除关联的对象
+func (a *Association) Delete(values ...interface{}) error {
+	if err := a.checkOwner(); err != nil {
+		return err
+	}
+	targets, err := a.targets(values)
+	if err != nil {
+		return err
+	}
+	keys := a.keysOf(targets)
+
+	switch a.rel.Type {
+	case schema.HasOne, schema.HasMany:
+		cond := clause.Cond(a.quote(a.rel.ForeignKey.Name)+" = ?", a.ownerKey()).
+			Where(a.quote(a.rel.Schema.PrimaryField.Name)+" IN (?)", keys)
+		err = a.nullForeignKey(cond)
+	case schema.BelongsTo:
+		current := keyOf(a.rel.ForeignKey.ValueOf(a.owner))
+		for _, target := range targets {
+			if keyOf(a.rel.References.ValueOf(target)) == current {
+				err = a.clear(nil)
+			}
+		}
+	case schema.Many2Many:
+		err = a.deleteJoins(clause.Cond(a.quote(a.rel.JoinReferences)+" IN (?)", keys))
+	}
+	if err != nil {
+		return err
+	}
+
+	// 从当前对象的关联字段中移除
+	removed := map[string]bool{}
+	for _, target := range targets {
+		removed[keyOf(a.targetKey(target))] = true
+	}
+	var remain []reflect.Value
+	for _, v := range a.loaded() {
+		if !removed[keyOf(a.targetKey(v))] {
+			remain = append(remain, v)
+		}
+	}
+	setRelation(a.rel, a.owner, remain)
+	return nil
+}
+
+// Clear移除当前对象所有的关联，但不会删除关联的对象
+func (a *Association) Clear() error {
+	if err := a.checkOwner(); err != nil {
+		return err
+	}
+	if err := a.clear(nil); err != nil {
+		return err
+	}
+	setRelation(a.rel, a.owner, nil)
+	return nil
+}
+
+// Count查询当前对象关联的数量
+func (a *Association) Count() (int64, error) {
+	if a.err != nil {
+		return 0, a.err
+	}
+	if a.rel.Type != schema.BelongsTo && a.rel.References.ValueOf(a.owner).IsZero() {
+		// 当前对象还没有保存，不会有关联
+		return 0, nil
+	}
+
+	var table string
+	var cond *clause.Condition
+	switch a.rel.Type {
+	case schema.HasOne, schema.HasMany:
+		table, cond = a.rel.Schema.Name, clause.Cond(a.quote(a.rel.ForeignKey.Name)+" = ?", a.ownerKey())
+	case schema.BelongsTo:
+		fk := a.rel.ForeignKey.ValueOf(a.owner)
+		if fk.IsZero() {
+			return 0, nil
+		}
+		table, cond = a.rel.Schema.Name, clause.Cond(a.quote(a.rel.References.Name)+" = ?", fk.Interface())
+	case schema.Many2Many:
+		table, cond = a.rel.JoinTable, clause.Cond(a.quote(a.rel.JoinForeignKey)+" = ?", a.ownerKey())
+	}
+
+	where, vars := cond.Build()
+	row := a.s.clone().Raw(fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", a.quote(table), where), vars...).QueryRow()
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+// clear移除除了keep以外的所有关联
+func (a *Association) clear(keep []reflect.Value) error {
+	switch a.rel.Type {
+	case schema.HasOne, schema.HasMany:
+		cond := clause.Cond(a.quote(a.rel.ForeignKey.Name)+" = ?", a.ownerKey())
+		if keys := a.keysOf(keep); len(keys) > 0 {
+			cond.Not(a.quote(a.rel.Schema.PrimaryField.Name)+" IN (?)", keys)
+		}
+		return a.nullForeignKey(cond)
+	case schema.BelongsTo:
+		if len(keep) > 0 {
+			return nil
+		}
+		fk := a.rel.ForeignKey.Settable(a.owner)
+		fk.Set(reflect.Zero(fk.Type()))
+		return a.updateOwner(a.rel.ForeignKey, fk.Interface())
+	case schema.Many2Many:
+		var cond *clause.Condition
+		if keys := a.keysOf(keep); len(keys) > 0 {
+			cond = new(clause.Condition).Not(a.quote(a.rel.JoinReferences)+" IN (?)", keys)
+		}
+		return a.deleteJoins(cond)
+	}
+	return nil
+}
+
+// appendHas将关联对象的外键设置为当前对象的键，再插入或者更新它们
+func (a *Association) appendHas(targets []reflect.Value) error {
+	for _, target := range targets {
+		setValue(a.rel.ForeignKey.Settable(target), a.rel.References.ValueOf(a.owner))
+		if err := a.save(target, a.rel.ForeignKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendBelongsTo将当前对象的外键设置为关联对象的键
+func (a *Association) appendBelongsTo(targets []reflect.Value) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	if err := a.save(targets[0], nil); err != nil {
+		return err
+	}
+
+	fk := a.rel.ForeignKey.Settable(a.owner)
+	setValue(fk, a.rel.References.ValueOf(targets[0]))
+	return a.updateOwner(a.rel.ForeignKey, fk.Interface())
+}
+
+// appendMany2Many保存关联对象，再写入中间表，已经存在的关联会被覆盖
+func (a *Association) appendMany2Many(targets []reflect.Value) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	for _, target := range targets {
+		if err := a.save(target, nil); err != nil {
+			return err
+		}
+	}
+
+	keys := a.keysOf(targets)
+	if err := a.deleteJoins(clause.Cond(a.quote(a.rel.JoinReferences)+" IN (?)", keys)); err != nil {
+		return err
+	}
+
+	var rows []interface{}
+	for _, key := range keys {
+		rows = append(rows, []interface{}{a.ownerKey(), key})
+	}
+	sub := a.s.clone()
+	sub.clause.Set(clause.INSERT, a.rel.JoinTable, []string{a.rel.JoinForeignKey, a.rel.JoinReferences})
+	sub.clause.Set(clause.VALUES, rows...)
+	sql, vars := sub.clause.Build(clause.INSERT, clause.VALUES)
+	_, err := sub.Raw(sql, vars...).Exec()
+	return err
+}
+
+// save保存关联对象，主键为零值或者数据库中不存在的对象会被插入，其他的对象只更新field
+func (a *Association) save(target reflect.Value, field *schema.Field) error {
+	primary := a.rel.Schema.PrimaryField
+	if primary != nil && !primary.ValueOf(target).IsZero() {
+		var affected int64
+		var err error
+		s := a.s.clone().Model(target.Addr().Interface()).Where(a.quote(primary.Name)+" = ?", primary.ValueOf(target).Interface())
+		if field != nil {
+			affected, err = s.Update(field.Name, field.ValueOf(target).Interface())
+		} else {
+			affected, err = s.Count()
+		}
+		if err != nil || affected > 0 {
+			return err
+		}
+	}
+
+	if _, err := a.s.clone().Insert(target.Addr().Interface()); err != nil {
+		return err
+	}
+	if primary != nil && primary.ValueOf(target).IsZero() {
+		return fmt.Errorf("association: primary key of %s is zero after insert", a.rel.Schema.ModelType.Name())
+	}
+	return nil
+}
+
+// updateOwner更新当前对象的一个字段
+func (a *Association) updateOwner(field *schema.Field, value interface{}) error {
+	table := a.table
+	if table.PrimaryField == nil {
+		return fmt.Errorf("association: %s has no primary key", table.ModelType.Name())
+	}
+	_, err := a.s.clone().Model(a.owner.Addr().Interface()).
+		Where(a.quote(table.PrimaryField.Name)+" = ?", table.PrimaryField.ValueOf(a.owner).Interface()).
+		Update(field.Name, value)
+	return err
+}
+
+// nullForeignKey将满足cond的关联对象的外键置空，外键不是指针时设置为零值
+func (a *Association) nullForeignKey(cond *clause.Condition) error {
+	fk := a.rel.ForeignKey
+	_, err := a.s.clone().Model(reflect.New(a.rel.Schema.ModelType).Interface()).
+		Where(cond).Update(fk.Name, reflect.Zero(fk.GoType).Interface())
+	return err
+}
+
+// deleteJoins删除中间表中当前对象满足cond的行，cond为nil时删除当前对象所有的行
+func (a *Association) deleteJoins(cond *clause.Condition) error {
+	where := clause.Cond(a.quote(a.rel.JoinForeignKey)+" = ?", a.ownerKey())
+	if cond != nil {
+		where.Where(cond)
+	}
+	desc, vars := where.Build()
+	_, err := a.s.clone().Raw(fmt.Sprintf("DELETE FROM %s WHERE %s", a.quote(a.rel.JoinTable), desc), vars...).Exec()
+	return err
+}
+
+// targets将values转化为关联模型的结构体，values需要是关联模型的结构体指针或结构体
+func (a *Association) targets(values []interface{}) ([]reflect.Value, error) {
+	var targets []reflect.Value
+	for _, value := range values {
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Ptr {
+			// 结构体不能被修改，复制一份
+			ptr := reflect.New(v.Type())
+			ptr.Elem().Set(v)
+			v = ptr
+		}
+		if v.Elem().Type() != a.rel.Schema.ModelType {
+			return nil, fmt.Errorf("association: %s expects %s, got %s", a.rel.Name, a.rel.Schema.ModelType, v.Elem().Type())
+		}
+		targets = append(targets, v.Elem())
+	}
+	// 一对一和属于只有最后一个对象会生效
+	if (a.rel.Type == schema.BelongsTo || a.rel.Type == schema.HasOne) && len(targets) > 1 {
+		targets = targets[len(targets)-1:]
+	}
+	return targets, nil
+}
+
+// loaded返回当前对象关联字段中已有的对象
+func (a *Association) loaded() []reflect.Value {
+	var values []reflect.Value
+	field := a.rel.ValueOf(a.owner)
+	if field.Kind() != reflect.Slice {
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			return nil
+		}
+		return []reflect.Value{reflect.Indirect(field)}
+	}
+	for i := 0; i < field.Len(); i++ {
+		if elem := field.Index(i); elem.Kind() != reflect.Ptr || !elem.IsNil() {
+			values = append(values, reflect.Indirect(elem))
+		}
+	}
+	return values
+}
+
+// checkOwner检查当前对象被关联引用的键，它是零值时当前对象还没有保存，
+// 继续操作会写入值为零的外键，或者修改外键为零的其他对象的关联
+func (a *Association) checkOwner() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.rel.Type != schema.BelongsTo && a.rel.References.ValueOf(a.owner).IsZero() {
+		return fmt.Errorf("association: %s.%s is zero, save %s before changing its associations",
+			a.table.ModelType.Name(), a.rel.References.GoName, a.table.ModelType.Name())
+	}
+	return nil
+}
+
+// ownerKey返回当前对象被关联引用的键
+func (a *Association) ownerKey() interface{} {
+	return a.rel.References.ValueOf(a.owner).Interface()
+}
+
+// targetKey返回关联对象用于识别的键，BelongsTo为被引用的字段，其他为主键
+func (a *Association) targetKey(target reflect.Value) reflect.Value {
+	if a.rel.Type == schema.BelongsTo {
+		return a.rel.References.ValueOf(target)
+	}
+	if a.rel.Schema.PrimaryField == nil {
+		return reflect.Value{}
+	}
+	return a.rel.Schema.PrimaryField.ValueOf(target)
+}
+
+func (a *Association) keysOf(targets []reflect.Value) []interface{} {
+	var keys []interface{}
+	for _, target := range targets {
+		if key := a.targetKey(target); key.IsValid() {
+			keys = append(keys, key.Interface())
+		}
+	}
+	return keys
+}
+
+func (a *Association) quote(name string) string {
+	return a.s.dialect.Quote(name)
+}
+
+// setValue将src写入dst，两者的类型可以不同，比如 int 和 *int64
+func setValue(dst, src reflect.Value) {
+	src = reflect.Indirect(src)
+	if dst.Kind() == reflect.Ptr {
+		if !src.IsValid() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		ptr := reflect.New(dst.Type().Elem())
+		ptr.Elem().Set(src.Convert(dst.Type().Elem()))
+		dst.Set(ptr)
+		return
+	}
+	dst.Set(src.Convert(dst.Type()))
+}
@@ -0,0 +1,50 @@
+package session
+
+import "testing"
+
+type assocUser struct {
+	ID     int64 `borm:"primaryKey;autoIncrement"`
+	Name   string
+	Orders []assocOrder `borm:"foreignKey:UserID"`
+}
+
+type assocOrder struct {
+	ID     int64 `borm:"primaryKey;autoIncrement"`
+	UserID int64
+	Item   string
+}
+
+func TestAssociationUnsavedOwner(t *testing.T) {
+	s := newSqliteSession(t, &assocUser{}, &assocOrder{})
+
+	user := &assocUser{Name: "unsaved"}
+	if err := s.Model(user).Association("Orders").Append(&assocOrder{Item: "book"}); err == nil {
+		t.Error("Append on unsaved owner should fail")
+	}
+	if err := s.Model(user).Association("Orders").Replace(&assocOrder{Item: "pen"}); err == nil {
+		t.Error("Replace on unsaved owner should fail")
+	}
+	if err := s.Model(user).Association("Orders").Clear(); err == nil {
+		t.Error("Clear on unsaved owner should fail")
+	}
+	if count, err := s.Model(user).Association("Orders").Count(); err != nil || count != 0 {
+		t.Errorf("Count = %d, %v, want 0, nil", count, err)
+	}
+	if count, _ := s.Model(&assocOrder{}).Count(); count != 0 {
+		t.Errorf("%d orders inserted for unsaved owner", count)
+	}
+
+	if _, err := s.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Model(user).Association("Orders").Append(&assocOrder{Item: "book"}); err != nil {
+		t.Fatal(err)
+	}
+	var orders []assocOrder
+	if err := s.Find(&orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].UserID != user.ID {
+		t.Errorf("orders = %v, want one order of user %d", orders, user.ID)
+	}
+}
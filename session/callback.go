@@ -0,0 +1,261 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CallbackFunc是一个回调函数，通过s.Statement()获取本次操作的数据，返回错误时会终止后续的回调
+type CallbackFunc func(s *Session) error
+
+// Statement是一次Insert、Find、Update或Delete操作的数据，在回调函数之间传递
+type Statement struct {
+	Values       []interface{}          //Insert的记录
+	Dest         interface{}            //Find的结果，结构体切片的指针
+	Updates      map[string]interface{} //Update的键值对，键可以是列名或者结构体字段名
+	RowsAffected int64                  //Insert、Update、Delete影响的行数
+
+	start int //Find之前Dest的长度，只处理本次查询到的记录
+}
+
+// Callbacks是回调函数的注册表，内置的增删查改也是注册在其中的回调
+// engine.Callback().Create().Before("borm:insert").Register("audit", fn)
+type Callbacks struct {
+	create *Processor
+	query  *Processor
+	update *Processor
+	delete *Processor
+}
+
+// Processor是一种操作的回调函数，按照顺序约束排序后依次执行
+type Processor struct {
+	mu        sync.RWMutex
+	callbacks []*Callback
+	fns       []CallbackFunc //排序后的回调函数，注册表变化时重新排序
+}
+
+// Callback是一个回调函数和它的顺序约束
+type Callback struct {
+	name      string
+	before    string
+	after     string
+	fn        CallbackFunc
+	processor *Processor
+}
+
+// NewCallbacks生成一个注册了内置回调的注册表
+func NewCallbacks() *Callbacks {
+	cb := &Callbacks{
+		create: &Processor{},
+		query:  &Processor{},
+		update: &Processor{},
+		delete: &Processor{},
+	}
+	registerDefaultCallbacks(cb)
+	return cb
+}
+
+// Create返回Insert的回调
+func (cb *Callbacks) Create() *Processor {
+	return cb.create
+}
+
+// Query返回Find的回调
+func (cb *Callbacks) Query() *Processor {
+	return cb.query
+}
+
+// Update返回Update的回调
+func (cb *Callbacks) Update() *Processor {
+	return cb.update
+}
+
+// Delete返回Delete的回调
+func (cb *Callbacks) Delete() *Processor {
+	return cb.delete
+}
+
+// Before约束回调在name之前执行
+func (p *Processor) Before(name string) *Callback {
+	return &Callback{before: name, processor: p}
+}
+
+// After约束回调在name之后执行
+func (p *Processor) After(name string) *Callback {
+	return &Callback{after: name, processor: p}
+}
+
+// Register注册一个没有顺序约束的回调，没有顺序约束的回调按注册顺序执行
+func (p *Processor) Register(name string, fn CallbackFunc) error {
+	return (&Callback{processor: p}).Register(name, fn)
+}
+
+// Remove删除名为name的回调
+func (p *Processor) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, c := range p.callbacks {
+		if c.name == name {
+			p.callbacks = append(p.callbacks[:i:i], p.callbacks[i+1:]...)
+			return p.compile()
+		}
+	}
+	return fmt.Errorf("callback %s not found", name)
+}
+
+// Replace替换名为name的回调函数，顺序约束保持不变
+func (p *Processor) Replace(name string, fn CallbackFunc) error {
+	if fn == nil {
+		return fmt.Errorf("callback %s is nil", name)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.callbacks {
+		if c.name == name {
+			c.fn = fn
+			return p.compile()
+		}
+	}
+	return fmt.Errorf("callback %s not found", name)
+}
+
+// Get返回名为name的回调函数，不存在时返回nil
+func (p *Processor) Get(name string) CallbackFunc {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, c := range p.callbacks {
+		if c.name == name {
+			return c.fn
+		}
+	}
+	return nil
+}
+
+// Before约束回调在name之前执行
+func (c *Callback) Before(name string) *Callback {
+	c.before = name
+	return c
+}
+
+// After约束回调在name之后执行
+func (c *Callback) After(name string) *Callback {
+	c.after = name
+	return c
+}
+
+// Register以name注册回调函数fn，name已经存在、fn为nil或者顺序约束有环时返回错误
+func (c *Callback) Register(name string, fn CallbackFunc) error {
+	if fn == nil {
+		return fmt.Errorf("callback %s is nil", name)
+	}
+	p := c.processor
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, exist := range p.callbacks {
+		if exist.name == name {
+			return fmt.Errorf("callback %s already registered", name)
+		}
+	}
+
+	c.name, c.fn = name, fn
+	p.callbacks = append(p.callbacks, c)
+	if err := p.compile(); err != nil {
+		p.callbacks = p.callbacks[:len(p.callbacks)-1]
+		return err
+	}
+	return nil
+}
+
+// Execute以stmt为本次操作的数据依次执行回调，有回调返回错误时停止并清理Session
+func (p *Processor) Execute(s *Session, stmt *Statement) (err error) {
+	p.mu.RLock()
+	fns := p.fns
+	p.mu.RUnlock()
+
+	// 钩子中可能在同一个Session上执行其他操作，结束后恢复之前的Statement
+	prev := s.statement
+	s.statement = stmt
+	defer func() {
+		s.statement = prev
+		if err != nil {
+			s.Clear()
+		}
+	}()
+
+	for _, fn := range fns {
+		if err = fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile按顺序约束对回调排序，约束相同的回调按注册顺序执行
+// 约束中的回调不存在时忽略这个约束
+func (p *Processor) compile() error {
+	index := map[string]int{}
+	for i, c := range p.callbacks {
+		index[c.name] = i
+	}
+
+	// edges[i]是必须在i之后执行的回调
+	edges := make([][]int, len(p.callbacks))
+	degree := make([]int, len(p.callbacks))
+	for i, c := range p.callbacks {
+		if j, ok := index[c.before]; ok && c.before != "" {
+			edges[i] = append(edges[i], j)
+			degree[j]++
+		}
+		if j, ok := index[c.after]; ok && c.after != "" {
+			edges[j] = append(edges[j], i)
+			degree[i]++
+		}
+	}
+
+	fns := make([]CallbackFunc, 0, len(p.callbacks))
+	done := make([]bool, len(p.callbacks))
+	for len(fns) < len(p.callbacks) {
+		next := -1
+		for i := range p.callbacks {
+			if !done[i] && degree[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return fmt.Errorf("callbacks have circular order constraints")
+		}
+		done[next] = true
+		fns = append(fns, p.callbacks[next].fn)
+		for _, j := range edges[next] {
+			degree[j]--
+		}
+	}
+	p.fns = fns
+	return nil
+}
+
+// registerDefaultCallbacks注册内置的增删查改，它们之间按 before -> 操作 -> after 的顺序执行
+func registerDefaultCallbacks(cb *Callbacks) {
+	defaults := []struct {
+		processor *Processor
+		name      string
+		fns       [3]CallbackFunc
+	}{
+		{cb.create, "insert", [3]CallbackFunc{beforeInsert, createCallback, afterInsert}},
+		{cb.query, "query", [3]CallbackFunc{beforeQuery, queryCallback, afterQuery}},
+		{cb.update, "update", [3]CallbackFunc{beforeUpdate, updateCallback, afterUpdate}},
+		{cb.delete, "delete", [3]CallbackFunc{beforeDelete, deleteCallback, afterDelete}},
+	}
+	for _, d := range defaults {
+		before, op, after := "borm:before_"+d.name, "borm:"+d.name, "borm:after_"+d.name
+		_ = d.processor.Register(before, d.fns[0])
+		_ = d.processor.After(before).Register(op, d.fns[1])
+		_ = d.processor.After(op).Register(after, d.fns[2])
+	}
+}
+
+// Statement返回正在执行的操作的数据，只在回调函数中有效
+func (s *Session) Statement() *Statement {
+	return s.statement
+}
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+// order执行p中排序后的回调，返回它们执行的顺序
+func order(t *testing.T, p *Processor) []string {
+	t.Helper()
+	var got []string
+	s := &Session{}
+	for _, fn := range p.fns {
+		if err := fn(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range s.history.String() {
+		got = append(got, string(c))
+	}
+	return got
+}
+
+// mark返回一个在history中记录name的回调
+func mark(name string) CallbackFunc {
+	return func(s *Session) error {
+		s.history.WriteString(name)
+		return nil
+	}
+}
+
+func TestProcessorCompile(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(p *Processor) error
+		want     []string
+	}{
+		{
+			name: "registration order",
+			register: func(p *Processor) error {
+				for _, n := range []string{"a", "b", "c"} {
+					if err := p.Register(n, mark(n)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "before",
+			register: func(p *Processor) error {
+				_ = p.Register("a", mark("a"))
+				_ = p.Register("b", mark("b"))
+				return p.Before("a").Register("c", mark("c"))
+			},
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "after",
+			register: func(p *Processor) error {
+				_ = p.After("b").Register("a", mark("a"))
+				_ = p.Register("b", mark("b"))
+				return p.Register("c", mark("c"))
+			},
+			want: []string{"b", "a", "c"},
+		},
+		{
+			name: "before and after",
+			register: func(p *Processor) error {
+				_ = p.Register("a", mark("a"))
+				_ = p.Register("c", mark("c"))
+				return p.After("a").Before("c").Register("b", mark("b"))
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "missing constraint is ignored",
+			register: func(p *Processor) error {
+				_ = p.Register("a", mark("a"))
+				return p.Before("x").Register("b", mark("b"))
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "remove",
+			register: func(p *Processor) error {
+				_ = p.Register("a", mark("a"))
+				_ = p.Register("b", mark("b"))
+				return p.Remove("a")
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "replace keeps order",
+			register: func(p *Processor) error {
+				_ = p.Register("a", mark("a"))
+				_ = p.Before("a").Register("b", mark("b"))
+				return p.Replace("a", mark("c"))
+			},
+			want: []string{"b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Processor{}
+			if err := tt.register(p); err != nil {
+				t.Fatal(err)
+			}
+			if got := order(t, p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessorCompileCycle(t *testing.T) {
+	p := &Processor{}
+	if err := p.Register("a", mark("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.After("a").Register("b", mark("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.After("b").Before("a").Register("c", mark("c")); err == nil {
+		t.Fatal("expected an error for circular constraints")
+	}
+	// 有环的回调不会被注册，之前的顺序不受影响
+	if got := order(t, p); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("order = %v, want [a b]", got)
+	}
+	if p.Get("c") != nil {
+		t.Error("callback c should not be registered")
+	}
+}
+
+func TestProcessorRegisterErrors(t *testing.T) {
+	p := &Processor{}
+	if err := p.Register("a", nil); err == nil {
+		t.Error("expected an error for nil callback")
+	}
+	if err := p.Register("a", mark("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register("a", mark("a")); err == nil {
+		t.Error("expected an error for duplicate name")
+	}
+	if err := p.Remove("x"); err == nil {
+		t.Error("expected an error for removing unknown callback")
+	}
+	if err := p.Replace("x", mark("x")); err == nil {
+		t.Error("expected an error for replacing unknown callback")
+	}
+}
+
+func TestDefaultCallbacksOrder(t *testing.T) {
+	p := NewCallbacks().Create()
+	if err := p.Before("borm:insert").Register("audit", mark("a")); err != nil {
+		t.Fatal(err)
+	}
+	// 替换内置的回调，保留它们的顺序约束
+	for name, m := range map[string]string{"borm:before_insert": "b", "borm:insert": "i", "borm:after_insert": "f"} {
+		if err := p.Replace(name, mark(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := order(t, p); !reflect.DeepEqual(got, []string{"b", "a", "i", "f"}) {
+		t.Errorf("order = %v, want [b a i f]", got)
+	}
+}
@@ -0,0 +1,61 @@
+package session
+
+import (
+	"github.com/tomygin/borm/dialect"
+	"github.com/tomygin/borm/schema"
+)
+
+// Conflict用于指定Insert冲突时的处理方式
+type Conflict struct {
+	s       *Session
+	columns []string
+}
+
+// OnConflict指定Insert判断冲突的列，列可以是列名或者结构体字段名，比如
+// s.OnConflict(clause.Columns("name")).DoUpdate("age").Insert(&user)
+// mysql由主键和唯一索引判断冲突，columns只用于DoNothing
+func (s *Session) OnConflict(columns []string) *Conflict {
+	return &Conflict{s: s, columns: columns}
+}
+
+// DoUpdate在冲突时用插入的值更新columns，columns为空时更新除冲突列和主键外所有插入的列
+func (c *Conflict) DoUpdate(columns ...string) *Session {
+	c.s.conflict = &dialect.Conflict{Columns: c.columns, Updates: columns}
+	return c.s
+}
+
+// DoNothing在冲突时忽略插入的行
+func (c *Conflict) DoNothing() *Session {
+	c.s.conflict = &dialect.Conflict{Columns: c.columns, DoNothing: true}
+	return c.s
+}
+
+// resolveConflict将冲突处理中的结构体字段名转化为列名，fields是插入的列
+func resolveConflict(table *schema.Schema, conflict *dialect.Conflict, fields []*schema.Field) *dialect.Conflict {
+	resolved := &dialect.Conflict{DoNothing: conflict.DoNothing}
+	if table.PrimaryField != nil {
+		resolved.Primary = table.PrimaryField.Name
+	}
+	skip := map[string]bool{}
+	for _, name := range conflict.Columns {
+		if field := table.GetField(name); field != nil {
+			name = field.Name
+		}
+		resolved.Columns = append(resolved.Columns, name)
+		skip[name] = true
+	}
+	for _, name := range conflict.Updates {
+		if field := table.GetField(name); field != nil {
+			name = field.Name
+		}
+		resolved.Updates = append(resolved.Updates, name)
+	}
+	if len(resolved.Updates) == 0 {
+		for _, field := range fields {
+			if !skip[field.Name] && field != table.PrimaryField {
+				resolved.Updates = append(resolved.Updates, field.Name)
+			}
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,169 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tomygin/borm/clause"
+	"github.com/tomygin/borm/dialect"
+)
+
+// fakeDriver记录收到的sql和变量，查询总是返回空结果
+type fakeDriver struct {
+	mu    sync.Mutex
+	stmts []fakeStmt
+}
+
+type fakeStmt struct {
+	query string
+	args  []interface{}
+}
+
+type fakeConn struct{ d *fakeDriver }
+
+type fakeRows struct{}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error)             { return fakeConn{d}, nil }
+func (d *fakeDriver) Connect(context.Context) (driver.Conn, error) { return fakeConn{d}, nil }
+func (d *fakeDriver) Driver() driver.Driver                        { return d }
+
+func (d *fakeDriver) record(query string, args []driver.NamedValue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stmt := fakeStmt{query: query}
+	for _, arg := range args {
+		stmt.args = append(stmt.args, arg.Value)
+	}
+	d.stmts = append(d.stmts, stmt)
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c fakeConn) Commit() error                       { return nil }
+func (c fakeConn) Rollback() error                     { return nil }
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.record(query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(query, args)
+	return fakeRows{}, nil
+}
+
+func (fakeRows) Columns() []string         { return nil }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+// newFakeSession生成一个使用fakeDriver和name方言的Session
+func newFakeSession(t *testing.T, name string) (*Session, *fakeDriver) {
+	t.Helper()
+	d := &fakeDriver{}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	dial, ok := dialect.GetDialect(name)
+	if !ok {
+		t.Fatalf("dialect %s not found", name)
+	}
+	return New(db, dial), d
+}
+
+type fakeUser struct {
+	ID   int64 `borm:"primaryKey;autoIncrement"`
+	Name string
+	Age  int
+}
+
+func TestPostgresBindVars(t *testing.T) {
+	s, d := newFakeSession(t, "postgres")
+
+	var users []fakeUser
+	if err := s.Where("name = ?", "a").Or("age IN (?)", []int{1, 2}).Limit(3).Find(&users); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Model(&fakeUser{}).Where("id = ?", 1).Update("Name", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Raw("SELECT '?' FROM users WHERE id = ?", 1).Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fakeStmt{
+		{`SELECT "id","name","age" FROM "fake_users"  WHERE (name = $1) OR (age IN ($2, $3)) LIMIT $4 `, []interface{}{"a", int64(1), int64(2), int64(3)}},
+		{`UPDATE "fake_users" SET "name" = $1 WHERE id = $2 `, []interface{}{"b", int64(1)}},
+		{`SELECT '?' FROM users WHERE id = $1 `, []interface{}{int64(1)}},
+	}
+	if !reflect.DeepEqual(d.stmts, want) {
+		t.Errorf("statements:\n got %q\nwant %q", d.stmts, want)
+	}
+}
+
+func TestMysqlDoNothingWithoutColumns(t *testing.T) {
+	s, d := newFakeSession(t, "mysql")
+
+	if _, err := s.OnConflict(nil).DoNothing().Insert(&fakeUser{Name: "a", Age: 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := "ON DUPLICATE KEY UPDATE `id` = `id`"
+	if len(d.stmts) != 1 || !strings.Contains(d.stmts[0].query, want) {
+		t.Errorf("statements = %q, want %q", d.stmts, want)
+	}
+}
+
+func TestPostgresDoUpdateWithoutColumns(t *testing.T) {
+	s, d := newFakeSession(t, "postgres")
+
+	if _, err := s.OnConflict(nil).DoUpdate("age").Insert(&fakeUser{Name: "a", Age: 1}); err != dialect.ErrConflictColumns {
+		t.Fatalf("err = %v, want %v", err, dialect.ErrConflictColumns)
+	}
+	if len(d.stmts) != 0 {
+		t.Errorf("statements = %q, want none", d.stmts)
+	}
+
+	// 出错后Session被清理，可以继续使用
+	if _, err := s.OnConflict(clause.Columns("name")).DoUpdate("age").Insert(&fakeUser{Name: "a", Age: 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := `ON CONFLICT ("name") DO UPDATE SET "age" = excluded."age"`
+	if len(d.stmts) != 1 || !strings.Contains(d.stmts[0].query, want) {
+		t.Errorf("statements = %q, want %q", d.stmts, want)
+	}
+}
+
+func TestNestedTransactionReleasesSavepoint(t *testing.T) {
+	s, d := newFakeSession(t, "postgres")
+
+	err := s.Transaction(func(s *Session) error {
+		if err := s.Transaction(func(s *Session) error { return nil }); err != nil {
+			return err
+		}
+		_ = s.Transaction(func(s *Session) error { return errors.New("rollback") })
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var queries []string
+	for _, stmt := range d.stmts {
+		queries = append(queries, strings.TrimSpace(stmt.query))
+	}
+	want := []string{
+		`SAVEPOINT "borm_sp1"`,
+		`RELEASE SAVEPOINT "borm_sp1"`,
+		`SAVEPOINT "borm_sp2"`,
+		`ROLLBACK TO SAVEPOINT "borm_sp2"`,
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("statements = %q, want %q", queries, want)
+	}
+}
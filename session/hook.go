@@ -2,6 +2,8 @@ package session
 
 import (
 	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/tomygin/borm/log"
 )
@@ -17,37 +19,161 @@ const (
 	AfterInsert  = "AfterInsert"
 )
 
-// CallMethod会调用Before,After系列的方法
-// 如果value为nil调用的对象就是当前数据库的那个对象
+// 模型实现下面的接口就会在对应的操作前后被调用，返回的错误会终止操作
+type BeforeQueryHook interface {
+	BeforeQuery(*Session) error
+}
+
+type AfterQueryHook interface {
+	AfterQuery(*Session) error
+}
+
+type BeforeUpdateHook interface {
+	BeforeUpdate(*Session) error
+}
+
+type AfterUpdateHook interface {
+	AfterUpdate(*Session) error
+}
+
+type BeforeDeleteHook interface {
+	BeforeDelete(*Session) error
+}
+
+type AfterDeleteHook interface {
+	AfterDelete(*Session) error
+}
+
+type BeforeInsertHook interface {
+	BeforeInsert(*Session) error
+}
+
+type AfterInsertHook interface {
+	AfterInsert(*Session) error
+}
+
+// hook通过类型断言调用value的钩子，value没有实现钩子时返回nil
+type hook func(s *Session, value interface{}) error
+
+// hooks装载各种钩子的调用方式，hookTypes是它们对应的接口，用于检查方法的签名
+var (
+	hooks     map[string]hook
+	hookTypes map[string]reflect.Type
+)
+
+// checkedHooks记录检查过钩子签名的模型
+var checkedHooks sync.Map
+
+// CallMethod会调用Before,After系列的方法，并返回方法返回的错误
+// 如果value为nil调用的对象就是Model传入的对象，它不是指针时是一个新的模型对象
 // 否者是value对象作为调用的对象
-func (s *Session) CallMethod(method string, value interface{}) {
+// After系列的方法返回错误时，所在的事务在Commit时会回滚
+func (s *Session) CallMethod(method string, value interface{}) error {
 
 	if !s.EnableHook {
-		return
+		return nil
 	}
 
-	//找到当前表 结构体 的 method 方法
-	fm := reflect.ValueOf(s.RefTable().Model).MethodByName(method)
+	if value == nil {
+		value = s.hookModel()
+	}
+	call, ok := hooks[method]
+	if !ok {
+		return nil
+	}
 
-	//如果有自定义结构体就不用表结构体
-	if value != nil {
-		fm = reflect.ValueOf(value).MethodByName(method)
+	if err := call(s, value); err != nil {
+		log.Error(err)
+		if strings.HasPrefix(method, "After") {
+			s.failTx(err)
+		}
+		return err
 	}
+	return nil
+}
 
-	param := []reflect.Value{reflect.ValueOf(s)}
+// hookModel返回没有指定记录时调用钩子的对象
+func (s *Session) hookModel() interface{} {
+	if v := reflect.ValueOf(s.model); v.Kind() == reflect.Ptr && !v.IsNil() {
+		return s.model
+	}
+	return reflect.New(s.RefTable().ModelType).Interface()
+}
 
-	if fm.IsValid() {
-		if v := fm.Call(param); len(v) > 0 {
-			if err, ok := v[0].Interface().(error); ok {
-				// panic(err)
-				log.Error(err)
-			}
+// checkHooks检查模型上和钩子同名的方法，签名不对时它不会被调用，所以打印警告
+// 每个模型只检查一次
+func checkHooks(typ reflect.Type) {
+	if _, checked := checkedHooks.LoadOrStore(typ, true); checked {
+		return
+	}
+	ptr := reflect.PtrTo(typ)
+	for name, iface := range hookTypes {
+		if method, ok := ptr.MethodByName(name); ok && !ptr.Implements(iface) {
+			log.Errorf("method %s.%s is %s, hooks must be func(*session.Session) error, it will not be called\n",
+				typ.Name(), name, method.Type)
 		}
 	}
-
 }
 
-// 在hook中如果执行失败可以调用来结束hook后相关的 增删查改
-// func (s *Session) Abort() {
-// 	s.abort = true
-// }
+func init() {
+	hooks = make(map[string]hook)
+	hooks[BeforeQuery] = func(s *Session, value interface{}) error {
+		if h, ok := value.(BeforeQueryHook); ok {
+			return h.BeforeQuery(s)
+		}
+		return nil
+	}
+	hooks[AfterQuery] = func(s *Session, value interface{}) error {
+		if h, ok := value.(AfterQueryHook); ok {
+			return h.AfterQuery(s)
+		}
+		return nil
+	}
+	hooks[BeforeUpdate] = func(s *Session, value interface{}) error {
+		if h, ok := value.(BeforeUpdateHook); ok {
+			return h.BeforeUpdate(s)
+		}
+		return nil
+	}
+	hooks[AfterUpdate] = func(s *Session, value interface{}) error {
+		if h, ok := value.(AfterUpdateHook); ok {
+			return h.AfterUpdate(s)
+		}
+		return nil
+	}
+	hooks[BeforeDelete] = func(s *Session, value interface{}) error {
+		if h, ok := value.(BeforeDeleteHook); ok {
+			return h.BeforeDelete(s)
+		}
+		return nil
+	}
+	hooks[AfterDelete] = func(s *Session, value interface{}) error {
+		if h, ok := value.(AfterDeleteHook); ok {
+			return h.AfterDelete(s)
+		}
+		return nil
+	}
+	hooks[BeforeInsert] = func(s *Session, value interface{}) error {
+		if h, ok := value.(BeforeInsertHook); ok {
+			return h.BeforeInsert(s)
+		}
+		return nil
+	}
+	hooks[AfterInsert] = func(s *Session, value interface{}) error {
+		if h, ok := value.(AfterInsertHook); ok {
+			return h.AfterInsert(s)
+		}
+		return nil
+	}
+
+	hookTypes = map[string]reflect.Type{
+		BeforeQuery:  reflect.TypeOf((*BeforeQueryHook)(nil)).Elem(),
+		AfterQuery:   reflect.TypeOf((*AfterQueryHook)(nil)).Elem(),
+		BeforeUpdate: reflect.TypeOf((*BeforeUpdateHook)(nil)).Elem(),
+		AfterUpdate:  reflect.TypeOf((*AfterUpdateHook)(nil)).Elem(),
+		BeforeDelete: reflect.TypeOf((*BeforeDeleteHook)(nil)).Elem(),
+		AfterDelete:  reflect.TypeOf((*AfterDeleteHook)(nil)).Elem(),
+		BeforeInsert: reflect.TypeOf((*BeforeInsertHook)(nil)).Elem(),
+		AfterInsert:  reflect.TypeOf((*AfterInsertHook)(nil)).Elem(),
+	}
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomygin/borm/clause"
+	"github.com/tomygin/borm/schema"
+)
+
+// joinSep连接关联名和列名，作为连接查询的列的别名，比如 Profile__bio
+const joinSep = "__"
+
+// join是一次Joins调用
+type join struct {
+	query string
+	args  []interface{}
+}
+
+// Joins添加一个连接，query可以是完整的连接语句，比如
+// s.Joins("LEFT JOIN orders ON orders.user_id = users.id")
+// 也可以是一对一或者属于关联的名字，比如 s.Joins("Profile")
+// 关联的列会以 Profile__bio 这样的别名查询，Find时写入关联字段，没有连接到数据时关联字段保持零值
+func (s *Session) Joins(query string, args ...interface{}) *Session {
+	s.joins = append(s.joins, join{query: query, args: args})
+	return s
+}
+
+// buildJoins生成连接子句，返回通过关联连接查询的列
+func (s *Session) buildJoins(table *schema.Schema) ([]string, error) {
+	if len(s.joins) == 0 {
+		return nil, nil
+	}
+
+	var sqls, columns []string
+	var vars []interface{}
+	for _, j := range s.joins {
+		// 包含空格的是完整的连接语句
+		if strings.ContainsAny(strings.TrimSpace(j.query), " \t\n") {
+			sqls = append(sqls, j.query)
+			vars = append(vars, j.args...)
+			continue
+		}
+
+		rel := table.GetRelation(j.query)
+		if rel == nil {
+			return nil, fmt.Errorf("joins: %s has no relation %s", table.ModelType.Name(), j.query)
+		}
+		if rel.Type != schema.HasOne && rel.Type != schema.BelongsTo {
+			return nil, fmt.Errorf("joins: %s is %s, only has_one and belongs_to can be joined", rel.Name, rel.Type)
+		}
+		sqls = append(sqls, s.joinRelation(table, rel))
+		for _, name := range rel.Schema.FieldNames {
+			columns = append(columns, fmt.Sprintf("%s.%s AS %s",
+				s.dialect.Quote(rel.Name), s.dialect.Quote(name), s.dialect.Quote(rel.Name+joinSep+name)))
+		}
+	}
+	s.clause.Set(clause.JOINS, append([]interface{}{strings.Join(sqls, " ")}, vars...)...)
+	return columns, nil
+}
+
+// joinRelation生成连接关联的语句，关联的表以关联名作为别名
+// LEFT JOIN profiles AS Profile ON Profile.user_id = users.id
+func (s *Session) joinRelation(table *schema.Schema, rel *schema.Relation) string {
+	alias := s.dialect.Quote(rel.Name)
+	relKey, ownerKey := rel.ForeignKey, rel.References
+	if rel.Type == schema.BelongsTo {
+		relKey, ownerKey = rel.References, rel.ForeignKey
+	}
+	return fmt.Sprintf("LEFT JOIN %s AS %s ON %s.%s = %s.%s",
+		s.dialect.Quote(rel.Schema.Name), alias,
+		alias, s.dialect.Quote(relKey.Name),
+		s.dialect.Quote(table.Name), s.dialect.Quote(ownerKey.Name))
+}
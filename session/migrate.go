@@ -0,0 +1,192 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomygin/borm/log"
+	"github.com/tomygin/borm/schema"
+)
+
+// ColumnTypeChange是一个类型发生变化的列
+type ColumnTypeChange struct {
+	Table  string
+	Column string
+	From   string //数据库中的类型
+	To     string //结构体对应的类型
+}
+
+// TypeChangedError在AutoMigrate发现列类型变化时返回，此时其他的迁移已经完成
+type TypeChangedError struct {
+	Changes []ColumnTypeChange
+}
+
+func (e *TypeChangedError) Error() string {
+	var changes []string
+	for _, c := range e.Changes {
+		changes = append(changes, fmt.Sprintf("%s.%s from %s to %s", c.Table, c.Column, c.From, c.To))
+	}
+	return "column type changed, please migrate it manually: " + strings.Join(changes, ", ")
+}
+
+// AutoMigrate根据结构体同步表结构
+// 表不存在时建表，表存在时添加缺少的列和索引
+// 类型发生变化的列不会修改，迁移完成后通过*TypeChangedError返回，需要手动处理
+func (s *Session) AutoMigrate(values ...interface{}) error {
+	var changes []ColumnTypeChange
+	for _, value := range values {
+		changed, err := s.Model(value).migrate()
+		if err != nil {
+			return err
+		}
+		changes = append(changes, changed...)
+	}
+	if len(changes) > 0 {
+		return &TypeChangedError{Changes: changes}
+	}
+	return nil
+}
+
+func (s *Session) migrate() (changes []ColumnTypeChange, err error) {
+	table := s.RefTable()
+	if !s.IsExistTable() {
+		err = s.CreateTable()
+	} else if s.tx != nil {
+		// mysql的DDL会隐式提交，保存点随之失效，已经在事务中时不再嵌套
+		changes, err = s.migrateColumns()
+	} else {
+		// 支持事务DDL的数据库(sqlite、postgres)中，失败时已经添加的列也会回滚
+		err = s.Transaction(func(s *Session) (err error) {
+			changes, err = s.migrateColumns()
+			return
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rel := range table.Relations {
+		if rel.Type == schema.Many2Many && !s.tableExists(rel.JoinTable) {
+			if err := s.createJoinTable(rel); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// createJoinTable创建many2many的中间表，两个外键列组成联合主键
+func (s *Session) createJoinTable(rel *schema.Relation) error {
+	fk, ref := s.dialect.Quote(rel.JoinForeignKey), s.dialect.Quote(rel.JoinReferences)
+	sql := fmt.Sprintf("CREATE TABLE %s (%s %s,%s %s, PRIMARY KEY (%s, %s));", s.dialect.Quote(rel.JoinTable),
+		fk, rel.References.Type, ref, rel.Schema.PrimaryField.Type, fk, ref)
+	_, err := s.Raw(sql).Exec()
+	return err
+}
+
+// migrateColumns给已经存在的表添加缺少的列和索引，返回类型发生变化的列
+func (s *Session) migrateColumns() ([]ColumnTypeChange, error) {
+	table := s.RefTable()
+
+	columns, err := s.columnTypes()
+	if err != nil {
+		return nil, err
+	}
+	var changes []ColumnTypeChange
+	for _, field := range table.Fields {
+		typ, ok := columns[strings.ToLower(field.Name)]
+		if !ok {
+			if err := s.addColumn(field); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// 自增和指定了长度的列在建表时类型会被方言改写，不做比较
+		if !field.AutoIncrement && field.Size == 0 && baseType(typ) != baseType(field.Type) {
+			log.Errorf("column %s.%s type changed from %s to %s, please migrate it manually\n",
+				table.Name, field.Name, typ, field.Type)
+			changes = append(changes, ColumnTypeChange{Table: table.Name, Column: field.Name, From: typ, To: field.Type})
+		}
+	}
+
+	indexes, err := s.indexNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range table.Indexes {
+		if !indexes[strings.ToLower(index.Name)] {
+			if err := s.createIndex(index); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// addColumn给当前表添加一列
+// sqlite不能添加UNIQUE的列，所以先添加普通的列，再单独创建唯一索引
+func (s *Session) addColumn(field *schema.Field) error {
+	table := s.RefTable()
+	column := field.Column
+	column.Unique = false
+	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", s.dialect.Quote(table.Name),
+		s.dialect.Quote(field.Name), s.dialect.ColumnSql(&column))
+	if _, err := s.Raw(sql).Exec(); err != nil {
+		return err
+	}
+	if !field.Unique {
+		return nil
+	}
+	name := strings.ReplaceAll("uni_"+table.Name+"_"+field.Name, ".", "_")
+	return s.createIndex(&schema.Index{Name: name, Unique: true, Fields: []*schema.Field{field}})
+}
+
+// columnTypes查询当前表已有的列，返回 小写的列名 -> 类型
+func (s *Session) columnTypes() (map[string]string, error) {
+	sql, vars := s.dialect.ColumnsSql(s.RefTable().Name)
+	rows, err := s.Raw(sql, vars...).QueryRows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		columns[strings.ToLower(name)] = typ
+	}
+	return columns, rows.Err()
+}
+
+// indexNames查询当前表已有的索引，索引名为小写
+func (s *Session) indexNames() (map[string]bool, error) {
+	sql, vars := s.dialect.IndexesSql(s.RefTable().Name)
+	rows, err := s.Raw(sql, vars...).QueryRows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		indexes[strings.ToLower(name)] = true
+	}
+	return indexes, rows.Err()
+}
+
+// baseType去掉类型中的长度和unsigned，用于比较类型是否变化
+// 比如 varchar(64) 为 varchar，INT UNSIGNED 为 int
+func baseType(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if i := strings.Index(typ, "("); i >= 0 {
+		typ = typ[:i]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(typ, " unsigned"))
+}
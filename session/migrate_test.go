@@ -0,0 +1,99 @@
+package session
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 同一张表迁移前后的两个版本
+type migrateUserV1 struct {
+	ID   int64 `borm:"primaryKey"`
+	Name string
+}
+
+func (migrateUserV1) TableName() string { return "migrate_users" }
+
+type migrateUserV2 struct {
+	ID    int64 `borm:"primaryKey"`
+	Name  string
+	Email string `borm:"unique"`
+}
+
+func (migrateUserV2) TableName() string { return "migrate_users" }
+
+type migrateUserBroken struct {
+	ID    int64 `borm:"primaryKey"`
+	Name  string
+	Age   int
+	Email string `borm:"notNull"`
+}
+
+func (migrateUserBroken) TableName() string { return "migrate_users" }
+
+type migrateUserRetyped struct {
+	ID   int64 `borm:"primaryKey"`
+	Name int
+	Age  int
+}
+
+func (migrateUserRetyped) TableName() string { return "migrate_users" }
+
+func TestAutoMigrateAddUniqueColumn(t *testing.T) {
+	s := newSqliteSession(t, &migrateUserV1{})
+	if _, err := s.Insert(&migrateUserV1{ID: 1, Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AutoMigrate(&migrateUserV2{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&migrateUserV2{ID: 2, Name: "b", Email: "b@x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&migrateUserV2{ID: 3, Name: "c", Email: "b@x"}); err == nil {
+		t.Error("expected a unique constraint error")
+	}
+}
+
+func TestAutoMigrateRollsBackAddedColumns(t *testing.T) {
+	s := newSqliteSession(t, &migrateUserV1{})
+	if _, err := s.Insert(&migrateUserV1{ID: 1, Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 表中有数据时sqlite不能添加没有默认值的NOT NULL列，前面添加的age也要回滚
+	if err := s.AutoMigrate(&migrateUserBroken{}); err == nil {
+		t.Fatal("expected an error when adding a NOT NULL column")
+	}
+	columns, err := s.Model(&migrateUserV1{}).columnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := columns["age"]; ok || len(columns) != 2 {
+		t.Errorf("columns = %v, want id and name", columns)
+	}
+}
+
+func TestAutoMigrateTypeChanged(t *testing.T) {
+	s := newSqliteSession(t, &migrateUserV1{})
+
+	err := s.AutoMigrate(&migrateUserRetyped{})
+	var changed *TypeChangedError
+	if !errors.As(err, &changed) {
+		t.Fatalf("err = %v, want *TypeChangedError", err)
+	}
+	want := []ColumnTypeChange{{Table: "migrate_users", Column: "name", From: "TEXT", To: "integer"}}
+	if !reflect.DeepEqual(changed.Changes, want) {
+		t.Errorf("changes = %v, want %v", changed.Changes, want)
+	}
+
+	// 类型变化不影响其他列的迁移
+	columns, err := s.Model(&migrateUserV1{}).columnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := columns["age"]; !ok {
+		t.Errorf("columns = %v, want age to be added", columns)
+	}
+}
@@ -0,0 +1,113 @@
+package session
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/tomygin/borm/schema"
+)
+
+// Save按主键保存value，value是结构体指针
+// 主键为零值或者数据库中不存在时插入，否则更新除主键外所有的列
+func (s *Session) Save(value interface{}) (int64, error) {
+	table, primary, err := s.primaryOf(value)
+	if err != nil {
+		s.Clear()
+		return 0, err
+	}
+	dest := reflect.Indirect(reflect.ValueOf(value))
+	if primary.ValueOf(dest).IsZero() {
+		s.Clear()
+		return s.Insert(value)
+	}
+
+	id := primary.ValueOf(dest).Interface()
+	columns := make(map[string]interface{}, len(table.Fields))
+	for _, field := range table.Fields {
+		if field != primary {
+			columns[field.Name] = field.ValueOf(dest).Interface()
+		}
+	}
+	affected, err := s.wherePrimary(primary, id).Update(columns)
+	if err != nil || affected > 0 {
+		return affected, err
+	}
+
+	// mysql默认返回被修改的行数，值没有变化时也是0，所以再确认一次是否存在
+	count, err := s.clone().Model(value).wherePrimary(primary, id).Count()
+	if err != nil || count > 0 {
+		return 0, err
+	}
+	return s.clone().Insert(value)
+}
+
+// UpdateModel按主键更新value中不是零值的字段，value是结构体指针
+func (s *Session) UpdateModel(value interface{}) (int64, error) {
+	table, primary, err := s.primaryOf(value)
+	if err != nil {
+		s.Clear()
+		return 0, err
+	}
+	dest := reflect.Indirect(reflect.ValueOf(value))
+	id := primary.ValueOf(dest)
+	if id.IsZero() {
+		s.Clear()
+		return 0, fmt.Errorf("update: primary key of %s is zero", table.ModelType.Name())
+	}
+
+	columns := map[string]interface{}{}
+	for _, field := range table.Fields {
+		if v := field.ValueOf(dest); field != primary && !v.IsZero() {
+			columns[field.Name] = v.Interface()
+		}
+	}
+	if len(columns) == 0 {
+		s.Clear()
+		return 0, nil
+	}
+	return s.wherePrimary(primary, id.Interface()).Update(columns)
+}
+
+// DeleteModel按主键删除value，value是结构体指针或者结构体
+func (s *Session) DeleteModel(value interface{}) (int64, error) {
+	table, primary, err := s.primaryOf(value)
+	if err != nil {
+		s.Clear()
+		return 0, err
+	}
+	id := primary.ValueOf(reflect.Indirect(reflect.ValueOf(value)))
+	if id.IsZero() {
+		s.Clear()
+		return 0, fmt.Errorf("delete: primary key of %s is zero", table.ModelType.Name())
+	}
+	return s.wherePrimary(primary, id.Interface()).Delete()
+}
+
+// DeleteByIDs删除主键在ids中的行，需要先用Model指定模型
+// s.Model(&User{}).DeleteByIDs(1, 2, 3)
+func (s *Session) DeleteByIDs(ids ...interface{}) (int64, error) {
+	if len(ids) == 0 {
+		s.Clear()
+		return 0, nil
+	}
+	table := s.RefTable()
+	if table.PrimaryField == nil {
+		s.Clear()
+		return 0, fmt.Errorf("delete: %s has no primary key", table.ModelType.Name())
+	}
+	return s.Where(s.dialect.Quote(table.PrimaryField.Name)+" IN (?)", ids).Delete()
+}
+
+// primaryOf设置value为模型，并返回它的主键
+func (s *Session) primaryOf(value interface{}) (*schema.Schema, *schema.Field, error) {
+	table := s.Model(value).RefTable()
+	if table.PrimaryField == nil {
+		return nil, nil, fmt.Errorf("%s has no primary key", table.ModelType.Name())
+	}
+	return table, table.PrimaryField, nil
+}
+
+// wherePrimary添加 主键 = id 的条件，之前的条件中有OR时会被整体括起来，只会操作主键为id的行
+func (s *Session) wherePrimary(primary *schema.Field, id interface{}) *Session {
+	return s.Where(s.dialect.Quote(primary.Name)+" = ?", id)
+}
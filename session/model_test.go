@@ -0,0 +1,88 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+type modelUser struct {
+	ID   int64 `borm:"primaryKey;autoIncrement"`
+	Name string
+	Age  int
+}
+
+// newModelSession插入 a、b、c 三个用户，主键依次为 1、2、3
+func newModelSession(t *testing.T) *Session {
+	t.Helper()
+	s := newSqliteSession(t, &modelUser{})
+	if _, err := s.Insert(&modelUser{Name: "a"}, &modelUser{Name: "b"}, &modelUser{Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func modelUsers(t *testing.T, s *Session) []modelUser {
+	t.Helper()
+	var users []modelUser
+	if err := s.OrderBy("id").Find(&users); err != nil {
+		t.Fatal(err)
+	}
+	return users
+}
+
+// 已有的OR条件不能改变主键条件的作用范围
+func TestModelPrimaryKeyWithOr(t *testing.T) {
+	t.Run("DeleteModel", func(t *testing.T) {
+		s := newModelSession(t)
+		affected, err := s.Where("name = ?", "a").Or("name = ?", "zzz").DeleteModel(&modelUser{ID: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if affected != 0 {
+			t.Errorf("affected = %d, want 0", affected)
+		}
+		if got := modelUsers(t, s); len(got) != 3 {
+			t.Errorf("rows = %v, want all 3 rows", got)
+		}
+	})
+
+	t.Run("UpdateModel", func(t *testing.T) {
+		s := newModelSession(t)
+		affected, err := s.Where("name = ?", "b").Or("name = ?", "c").UpdateModel(&modelUser{ID: 3, Age: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if affected != 1 {
+			t.Errorf("affected = %d, want 1", affected)
+		}
+		want := []modelUser{{1, "a", 0}, {2, "b", 0}, {3, "c", 30}}
+		if got := modelUsers(t, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("rows = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Save", func(t *testing.T) {
+		s := newModelSession(t)
+		if _, err := s.Where("name = ?", "a").Or("name = ?", "b").Save(&modelUser{ID: 2, Name: "saved"}); err != nil {
+			t.Fatal(err)
+		}
+		want := []modelUser{{1, "a", 0}, {2, "saved", 0}, {3, "c", 0}}
+		if got := modelUsers(t, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("rows = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestDeleteByIDs(t *testing.T) {
+	s := newModelSession(t)
+	affected, err := s.Model(&modelUser{}).DeleteByIDs(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 2 {
+		t.Errorf("affected = %d, want 2", affected)
+	}
+	if got := modelUsers(t, s); !reflect.DeepEqual(got, []modelUser{{2, "b", 0}}) {
+		t.Errorf("rows = %v, want only b", got)
+	}
+}
@@ -1,68 +1,315 @@
 package session
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"reflect"
 
 	"github.com/tomygin/borm/clause"
+	"github.com/tomygin/borm/dialect"
+	"github.com/tomygin/borm/log"
+	"github.com/tomygin/borm/schema"
 )
 
+// ErrMissingWhereClause 没有条件的Update和Delete会修改整张表，默认不允许执行
+// 确实需要时先调用AllowGlobalUpdate
+var ErrMissingWhereClause = errors.New("missing where clause, use AllowGlobalUpdate to update or delete all rows")
+
+// Insert插入values，依次执行Create中注册的回调
+// 每一条记录都会调用它的BeforeInsert和AfterInsert钩子，BeforeInsert返回错误时不会插入，钩子的错误会被返回
 func (s *Session) Insert(values ...interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	stmt := &Statement{Values: values}
+	err := s.callbacks.Create().Execute(s, stmt)
+	return stmt.RowsAffected, err
+}
+
+// beforeInsert是内置的回调 borm:before_insert
+func beforeInsert(s *Session) error {
+	for _, value := range s.Statement().Values {
+		if err := s.CallMethod(BeforeInsert, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCallback是内置的回调 borm:insert
+func createCallback(s *Session) (err error) {
+	stmt := s.Statement()
+	stmt.RowsAffected, err = s.insert(stmt.Values)
+	return
+}
+
+// afterInsert是内置的回调 borm:after_insert
+func afterInsert(s *Session) error {
+	for _, value := range s.Statement().Values {
+		if err := s.CallMethod(AfterInsert, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert插入values，自增和有默认值的字段在values中有的是零值有的不是时，分成多条语句插入
+// 不在事务中时这几条语句在同一个事务中执行
+func (s *Session) insert(values []interface{}) (int64, error) {
+	table := s.Model(values[0]).RefTable()
+	batches := s.insertBatches(table, values)
+	if len(batches) == 1 {
+		return s.insertBatch(table, values)
+	}
+
+	conflict := s.conflict
+	defer s.Clear()
+	var affected int64
+	run := func(tx *Session) error {
+		for _, batch := range batches {
+			sub := tx.clone()
+			sub.conflict = conflict
+			n, err := sub.insertBatch(table, batch)
+			if err != nil {
+				return err
+			}
+			affected += n
+		}
+		return nil
+	}
+	if s.tx != nil {
+		return affected, run(s)
+	}
+	if err := s.clone().Transaction(run); err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
 
-	s.CallMethod(BeforeInsert, nil)
-	defer s.CallMethod(AfterInsert, nil)
+// insertBatches将values分成可以用一条语句插入的批次
+// sqlite中RETURNING返回的行的顺序不确定，需要写回生成的主键时每行单独插入
+func (s *Session) insertBatches(table *schema.Schema, values []interface{}) [][]interface{} {
+	batches := table.InsertBatches(values)
+	primary := table.PrimaryField
+	if s.dialect.GeneratedKeys() != dialect.LastInsertID || primary == nil {
+		return batches
+	}
+	var split [][]interface{}
+	for _, batch := range batches {
+		if len(batch) == 1 || hasField(table.InsertFields(batch...), primary) {
+			split = append(split, batch)
+			continue
+		}
+		for _, value := range batch {
+			split = append(split, []interface{}{value})
+		}
+	}
+	return split
+}
+
+// insertBatch用一条语句插入values，它们需要写入的字段相同
+func (s *Session) insertBatch(table *schema.Schema, values []interface{}) (int64, error) {
+	s.Model(values[0])
+	fields := table.InsertFields(values...)
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	s.clause.Set(clause.INSERT, table.Name, names)
 
 	recordValues := make([]interface{}, 0)
 	for _, value := range values {
-		table := s.Model(value).RefTable()
-		s.clause.Set(clause.INSERT, table.Name, table.FieldNames)
-		recordValues = append(recordValues, table.RecordValues(value))
+		recordValues = append(recordValues, table.ValuesOf(value, fields))
 	}
 
 	s.clause.Set(clause.VALUES, recordValues...)
-	sql, vars := s.clause.Build(clause.INSERT, clause.VALUES)
+	upsert := s.conflict != nil
+	if upsert {
+		conflict, err := s.dialect.ConflictSql(resolveConflict(table, s.conflict, fields))
+		if err != nil {
+			s.Clear()
+			return 0, err
+		}
+		s.clause.Set(clause.ONCONFLICT, conflict)
+	}
+
+	// 主键交给数据库生成时，插入后写回values
+	primary := table.PrimaryField
+	generated := primary != nil && !hasField(fields, primary)
+	keys := s.dialect.GeneratedKeys()
+	// 有冲突处理时LastInsertId不可靠，单行的RETURNING可以和values对应
+	returning := generated && (keys == dialect.Returning || keys == dialect.LastInsertID && upsert)
+	if returning {
+		s.clause.Set(clause.RETURNING, primary.Name)
+	}
+	sql, vars := s.clause.Build(clause.INSERT, clause.VALUES, clause.ONCONFLICT, clause.RETURNING)
+	if returning {
+		return s.insertReturning(sql, vars, primary, values)
+	}
+
 	resout, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
 		return 0, err
 	}
-	return resout.RowsAffected()
+	affected, err := resout.RowsAffected()
+	// 有冲突处理或者部分行没有插入时，LastInsertId无法和values对应
+	if err != nil || !generated || !primary.AutoIncrement || upsert || affected != int64(len(values)) {
+		return affected, err
+	}
+	id, err := resout.LastInsertId()
+	if err != nil {
+		log.Error(err)
+		return affected, nil
+	}
+	// mysql多行插入时LastInsertId是第一行的主键，后面的行依次加1
+	// 这要求自增值是连续分配的，innodb_autoinc_lock_mode为0或1时成立，为2(mysql 8的默认值)时只有没有并发插入才成立
+	for i, value := range values {
+		setPrimary(primary, value, reflect.ValueOf(id+int64(i)))
+	}
+	return affected, nil
+}
+
+// insertReturning执行带有RETURNING的插入语句，将返回的主键按顺序写回values
+func (s *Session) insertReturning(sql string, vars []interface{}, primary *schema.Field, values []interface{}) (int64, error) {
+	rows, err := s.Raw(sql, vars...).QueryRows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []reflect.Value
+	for rows.Next() {
+		id := reflect.New(primary.GoType)
+		if err := rows.Scan(id.Interface()); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id.Elem())
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	// 冲突时被忽略的行不会返回，无法和values对应
+	if len(ids) == len(values) {
+		for i, value := range values {
+			setPrimary(primary, value, ids[i])
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+// setPrimary将id写入value的主键，value不是指针时无法写入
+func setPrimary(primary *schema.Field, value interface{}, id reflect.Value) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	setValue(primary.Settable(v.Elem()), id)
+}
+
+func hasField(fields []*schema.Field, field *schema.Field) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
 }
 
+// Find查询到values中，values是结构体切片的指针，依次执行Query中注册的回调
+// 查询前调用BeforeQuery钩子，返回错误时不会查询，查询到的每一条记录都会调用AfterQuery钩子
 func (s *Session) Find(values interface{}) error {
+	stmt := &Statement{Dest: values, start: reflect.Indirect(reflect.ValueOf(values)).Len()}
+	return s.callbacks.Query().Execute(s, stmt)
+}
 
-	s.CallMethod(BeforeQuery, nil)
+// beforeQuery是内置的回调 borm:before_query
+func beforeQuery(s *Session) error {
+	destType := reflect.Indirect(reflect.ValueOf(s.Statement().Dest)).Type().Elem()
+	return s.CallMethod(BeforeQuery, reflect.New(destType).Interface())
+}
 
-	defer s.CallMethod(AfterQuery, s.refTable.Model)
+// queryCallback是内置的回调 borm:query
+func queryCallback(s *Session) error {
+	return s.find(reflect.Indirect(reflect.ValueOf(s.Statement().Dest)))
+}
 
-	destSlice := reflect.Indirect(reflect.ValueOf(values))
+// afterQuery是内置的回调 borm:after_query，只处理本次查询到的记录
+func afterQuery(s *Session) error {
+	stmt := s.Statement()
+	destSlice := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+	for i := stmt.start; i < destSlice.Len(); i++ {
+		if err := s.CallMethod(AfterQuery, destSlice.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find将查询到的记录追加到destSlice
+func (s *Session) find(destSlice reflect.Value) error {
 	destType := destSlice.Type().Elem()
-	table := s.Model(reflect.New(destType).Elem().Interface()).RefTable()
+	// 使用Select时可以查询到和模型不同的结构体中，比如分组统计的结果，按列名写入
+	// 模型需要在本次操作中用Model指定，否则查询destSlice自己的表
+	table, scanTable := s.refTable, s.refTable
+	if len(s.selects) == 0 || !s.modelSet || table == nil || table.ModelType == destType {
+		table = s.Model(reflect.New(destType).Elem().Interface()).RefTable()
+		scanTable = table
+	} else {
+		scanTable = s.schemas.Parse(reflect.New(destType).Interface(), s.dialect)
+	}
+	preloads := s.preloads
 
-	s.clause.Set(clause.SELECT, table.Name, table.FieldNames)
-	sql, vars := s.clause.Build(clause.SELECT, clause.WHERE, clause.ORDERBY, clause.LIMIT, clause.OFFSET)
+	joinColumns, err := s.buildJoins(table)
+	if err != nil {
+		s.Clear()
+		return err
+	}
+	var columns []string
+	if len(s.selects) > 0 {
+		columns = s.columns(table, s.omit(table, s.selects))
+	} else {
+		columns = append(s.columns(table, s.omit(table, table.FieldNames)), joinColumns...)
+	}
+	s.clause.Set(clause.SELECT, table.Name, columns, s.distinct)
+	s.groupBy(table)
+	sql, vars := s.clause.Build(clause.SELECT, clause.JOINS, clause.WHERE, clause.GROUPBY, clause.HAVING, clause.ORDERBY, clause.LIMIT, clause.OFFSET)
 	rows, err := s.Raw(sql, vars...).QueryRows()
 	if err != nil {
 		return err
 	}
 
+	names, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return err
+	}
+	start := destSlice.Len()
 	for rows.Next() {
 		dest := reflect.New(destType).Elem()
-		var values []interface{}
-		for _, name := range table.FieldNames {
-			values = append(values, dest.FieldByName(name).Addr().Interface())
-		}
-		if err := rows.Scan(values...); err != nil {
+		if err := scanRow(rows, scanTable, dest, names); err != nil {
+			rows.Close()
 			return err
 		}
 		destSlice.Set(reflect.Append(destSlice, dest))
 	}
-	return rows.Close()
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	// 只为本次查询到的数据加载关联
+	if len(preloads) > 0 {
+		return s.preload(scanTable, destSlice.Slice(start, destSlice.Len()), preloads)
+	}
+	return nil
 }
 
+// Update更新满足条件的行，kv可以是一个map[string]interface{}，也可以是 键, 值, 键, 值 ...
+// 依次执行Update中注册的回调
 func (s *Session) Update(kv ...interface{}) (int64, error) {
-
-	s.CallMethod(BeforeUpdate, nil)
-	defer s.CallMethod(AfterUpdate, nil)
+	if err := s.checkWhere(); err != nil {
+		return 0, err
+	}
 
 	m, ok := kv[0].(map[string]interface{})
 	if !ok {
@@ -71,32 +318,97 @@ func (s *Session) Update(kv ...interface{}) (int64, error) {
 			m[kv[i].(string)] = kv[i+1]
 		}
 	}
-	s.clause.Set(clause.UPDATE, s.RefTable().Name, m)
+	stmt := &Statement{Updates: m}
+	err := s.callbacks.Update().Execute(s, stmt)
+	return stmt.RowsAffected, err
+}
+
+// beforeUpdate是内置的回调 borm:before_update
+func beforeUpdate(s *Session) error {
+	return s.CallMethod(BeforeUpdate, nil)
+}
+
+// updateCallback是内置的回调 borm:update
+func updateCallback(s *Session) error {
+	stmt := s.Statement()
+	// 键可以是列名也可以是结构体字段名，统一转化为列名
+	table := s.RefTable()
+	columns := make(map[string]interface{}, len(stmt.Updates))
+	for k, v := range stmt.Updates {
+		if field := table.GetField(k); field != nil {
+			k = field.Name
+		}
+		columns[k] = v
+	}
+	s.clause.Set(clause.UPDATE, table.Name, columns)
 	sql, vars := s.clause.Build(clause.UPDATE, clause.WHERE)
 	result, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return result.RowsAffected()
+	stmt.RowsAffected, err = result.RowsAffected()
+	return err
+}
+
+// afterUpdate是内置的回调 borm:after_update
+func afterUpdate(s *Session) error {
+	return s.CallMethod(AfterUpdate, nil)
 }
 
+// Delete删除满足条件的行，依次执行Delete中注册的回调
 func (s *Session) Delete() (int64, error) {
+	if err := s.checkWhere(); err != nil {
+		return 0, err
+	}
+	stmt := &Statement{}
+	err := s.callbacks.Delete().Execute(s, stmt)
+	return stmt.RowsAffected, err
+}
 
-	s.CallMethod(BeforeDelete, nil)
-	defer s.CallMethod(AfterDelete, nil)
+// beforeDelete是内置的回调 borm:before_delete
+func beforeDelete(s *Session) error {
+	return s.CallMethod(BeforeDelete, nil)
+}
 
+// deleteCallback是内置的回调 borm:delete
+func deleteCallback(s *Session) error {
 	s.clause.Set(clause.DELETE, s.RefTable().Name)
 	sql, vars := s.clause.Build(clause.DELETE, clause.WHERE)
 	result, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return result.RowsAffected()
+	s.Statement().RowsAffected, err = result.RowsAffected()
+	return err
+}
+
+// afterDelete是内置的回调 borm:after_delete
+func afterDelete(s *Session) error {
+	return s.CallMethod(AfterDelete, nil)
+}
+
+// AllowGlobalUpdate允许接下来的一次Update或Delete没有条件，修改整张表
+func (s *Session) AllowGlobalUpdate() *Session {
+	s.allowGlobalUpdate = true
+	return s
+}
+
+// checkWhere在没有条件并且没有调用AllowGlobalUpdate时返回ErrMissingWhereClause
+func (s *Session) checkWhere() error {
+	if s.clause.Where().Empty() && !s.allowGlobalUpdate {
+		s.Clear()
+		return ErrMissingWhereClause
+	}
+	return nil
 }
 
 func (s *Session) Count() (int64, error) {
+	if _, err := s.buildJoins(s.RefTable()); err != nil {
+		s.Clear()
+		return 0, err
+	}
 	s.clause.Set(clause.COUNT, s.RefTable().Name)
-	sql, vars := s.clause.Build(clause.COUNT, clause.WHERE)
+	sql, vars := s.clause.Build(clause.COUNT, clause.JOINS, clause.WHERE)
 	row := s.Raw(sql, vars...).QueryRow()
 
 	var tmp int64
@@ -105,6 +417,43 @@ func (s *Session) Count() (int64, error) {
 
 }
 
+// Sum返回column的和，column可以是列名、结构体字段名或者表达式，没有数据时返回0
+func (s *Session) Sum(column string) (float64, error) {
+	var tmp sql.NullFloat64
+	err := s.aggregate("SUM", column, &tmp)
+	return tmp.Float64, err
+}
+
+// Avg返回column的平均值，没有数据时返回0
+func (s *Session) Avg(column string) (float64, error) {
+	var tmp sql.NullFloat64
+	err := s.aggregate("AVG", column, &tmp)
+	return tmp.Float64, err
+}
+
+// Min将column的最小值写入dest，dest是指针
+func (s *Session) Min(column string, dest interface{}) error {
+	return s.aggregate("MIN", column, dest)
+}
+
+// Max将column的最大值写入dest，dest是指针
+func (s *Session) Max(column string, dest interface{}) error {
+	return s.aggregate("MAX", column, dest)
+}
+
+// aggregate查询 fn(column) 并写入dest
+func (s *Session) aggregate(fn, column string, dest interface{}) error {
+	table := s.RefTable()
+	if _, err := s.buildJoins(table); err != nil {
+		s.Clear()
+		return err
+	}
+	expr := fmt.Sprintf("%s(%s)", fn, s.quoteColumn(table, column))
+	s.clause.Set(clause.SELECT, table.Name, []string{expr})
+	sql, vars := s.clause.Build(clause.SELECT, clause.JOINS, clause.WHERE)
+	return s.Raw(sql, vars...).QueryRow().Scan(dest)
+}
+
 func (s *Session) Limit(num int) *Session {
 	s.clause.Set(clause.LIMIT, num)
 	return s
@@ -123,12 +472,119 @@ func (s *Session) Page(limit, offset int) *Session {
 	return s
 }
 
-func (s *Session) Where(desc string, args ...interface{}) *Session {
-	var vars []interface{}
-	s.clause.Set(clause.WHERE, append(append(vars, desc), args...)...)
+// Where添加一个条件，多次调用之间是AND关系
+// query可以是字符串，也可以是clause.Cond生成的一组条件
+func (s *Session) Where(query interface{}, args ...interface{}) *Session {
+	s.clause.Where().Where(query, args...)
+	return s
+}
+
+// Or添加一个和之前的条件为OR关系的条件
+func (s *Session) Or(query interface{}, args ...interface{}) *Session {
+	s.clause.Where().Or(query, args...)
+	return s
+}
+
+// Not添加一个取反的条件，和之前的条件为AND关系
+func (s *Session) Not(query interface{}, args ...interface{}) *Session {
+	s.clause.Where().Not(query, args...)
+	return s
+}
+
+// Select指定查询的列，可以是列名、结构体字段名，也可以是表达式，比如
+// s.Model(&User{}).Select("age, count(*) AS total").GroupBy("age").Find(&results)
+// 这时Find可以传入和模型不同的结构体，查询到的列按列名或者字段名写入
+func (s *Session) Select(columns ...string) *Session {
+	s.selects = append(s.selects, columns...)
+	return s
+}
+
+// Omit指定不查询的列，可以是列名或者结构体字段名，Find时这些字段保持零值
+func (s *Session) Omit(columns ...string) *Session {
+	s.omits = append(s.omits, columns...)
+	return s
+}
+
+// Distinct让查询的结果去重，columns不为空时同时指定查询的列，和Select一样
+func (s *Session) Distinct(columns ...string) *Session {
+	s.distinct = true
+	return s.Select(columns...)
+}
+
+// GroupBy按columns分组，columns可以是列名或者结构体字段名
+func (s *Session) GroupBy(columns ...string) *Session {
+	s.groups = append(s.groups, columns...)
+	return s
+}
+
+// Having添加一个分组后的过滤条件，多次调用之间是AND关系
+func (s *Session) Having(query interface{}, args ...interface{}) *Session {
+	s.clause.Having().Where(query, args...)
 	return s
 }
 
+// groupBy生成GROUP BY子句
+func (s *Session) groupBy(table *schema.Schema) {
+	if len(s.groups) == 0 {
+		return
+	}
+	var groups []interface{}
+	for _, column := range s.columns(table, s.groups) {
+		groups = append(groups, column)
+	}
+	s.clause.Set(clause.GROUPBY, groups...)
+}
+
+// omit去掉names中被Omit的列
+func (s *Session) omit(table *schema.Schema, names []string) []string {
+	if len(s.omits) == 0 {
+		return names
+	}
+	omitted := map[string]bool{}
+	for _, name := range s.omits {
+		if field := table.GetField(name); field != nil {
+			name = field.Name
+		}
+		omitted[name] = true
+	}
+	var columns []string
+	for _, name := range names {
+		if field := table.GetField(name); field != nil && omitted[field.Name] || omitted[name] {
+			continue
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// columns将names中的结构体字段名转化为列名，有连接时用表名限定列名，避免和连接的表重名
+// 不是字段的name被视为表达式，保持原样
+func (s *Session) columns(table *schema.Schema, names []string) []string {
+	columns := make([]string, 0, len(names))
+	for _, name := range names {
+		if field := table.GetField(name); field != nil {
+			name = field.Name
+			if len(s.joins) > 0 {
+				name = table.Name + "." + name
+			}
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// quoteColumn和columns一样转化name，并给列名加上引号
+func (s *Session) quoteColumn(table *schema.Schema, name string) string {
+	field := table.GetField(name)
+	if field == nil {
+		return name
+	}
+	if len(s.joins) > 0 {
+		return s.dialect.Quote(table.Name) + "." + s.dialect.Quote(field.Name)
+	}
+	return s.dialect.Quote(field.Name)
+}
+
 func (s *Session) OrderBy(desc string) *Session {
 	s.clause.Set(clause.ORDERBY, desc)
 	return s
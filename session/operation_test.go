@@ -0,0 +1,65 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+type selectUser struct {
+	ID   int64 `borm:"primaryKey;autoIncrement"`
+	Name string
+	Age  int
+}
+
+type selectOrder struct {
+	ID   int64 `borm:"primaryKey;autoIncrement"`
+	Name string
+}
+
+type ageCount struct {
+	Age   int
+	Total int
+}
+
+// 之前操作留下的模型不能决定Select查询的表
+func TestSelectUsesDestTableWithoutModel(t *testing.T) {
+	s := newSqliteSession(t, &selectUser{}, &selectOrder{})
+	if _, err := s.Insert(&selectUser{Name: "u", Age: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&selectOrder{Name: "o"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var users []selectUser
+	if err := s.Select("name").Find(&users); err != nil {
+		t.Fatal(err)
+	}
+	if want := []selectUser{{Name: "u"}}; !reflect.DeepEqual(users, want) {
+		t.Errorf("users = %v, want %v", users, want)
+	}
+}
+
+func TestSelectIntoResultStruct(t *testing.T) {
+	s := newSqliteSession(t, &selectUser{})
+	if _, err := s.Insert(&selectUser{Name: "a", Age: 1}, &selectUser{Name: "b", Age: 1}, &selectUser{Name: "c", Age: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []ageCount
+	if err := s.Model(&selectUser{}).Select("age", "count(*) AS total").GroupBy("age").OrderBy("age").Find(&results); err != nil {
+		t.Fatal(err)
+	}
+	if want := []ageCount{{1, 2}, {2, 1}}; !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+
+	// Model只对它所在的操作有效
+	var users []selectUser
+	if err := s.Select("name").OrderBy("id").Find(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].Name != "a" {
+		t.Errorf("users = %v, want 3 users", users)
+	}
+}
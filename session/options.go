@@ -1,5 +1,7 @@
 package session
 
+import "github.com/tomygin/borm/schema"
+
 /*
 频繁变动的属性字段应该用导出的，同时每个导出的都应该有一份注释
 
@@ -7,43 +9,27 @@ package session
 
 */
 
-// type options struct {
-// 	//默认开启
-// 	notNeedHistory bool
-
-// 	//默认关闭
-// 	needHook bool
-// }
-
-// // optionFunc统一开关函数
-// type optionFunc func(*options)
-
-// func (s *Session) Options(opts ...optionFunc) {
-// 	for _, opt := range opts {
-// 		opt(&s.opts)
-// 	}
-// }
-
-// func CloseHook() optionFunc {
-// 	return func(o *options) {
-// 		o.needHook = false
-// 	}
-// }
-
-// func OpenHook() optionFunc {
-// 	return func(o *options) {
-// 		o.needHook = true
-// 	}
-// }
-
-// func CloseHistory() optionFunc {
-// 	return func(o *options) {
-// 		o.notNeedHistory = true
-// 	}
-// }
-
-// func OpenHistory() optionFunc {
-// 	return func(o *options) {
-// 		o.notNeedHistory = false
-// 	}
-// }
+// Option用于在New的时候初始化Session中不可导出的字段
+type Option func(*Session)
+
+// WithNamingStrategy设置解析结构体时使用的命名策略
+// Session会使用一个单独的Schema缓存
+func WithNamingStrategy(namer schema.NamingStrategy) Option {
+	return func(s *Session) {
+		s.schemas = schema.NewCache(namer)
+	}
+}
+
+// WithSchemaCache设置Session解析结构体时使用的缓存，通常由Engine共享给它的所有Session
+func WithSchemaCache(cache *schema.Cache) Option {
+	return func(s *Session) {
+		s.schemas = cache
+	}
+}
+
+// WithCallbacks设置Session执行增删查改的回调，通常由Engine共享给它的所有Session
+func WithCallbacks(callbacks *Callbacks) Option {
+	return func(s *Session) {
+		s.callbacks = callbacks
+	}
+}
@@ -0,0 +1,194 @@
+package session
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tomygin/borm/clause"
+	"github.com/tomygin/borm/schema"
+)
+
+// Preload让Find和First同时加载关联，嵌套的关联用 . 连接，比如 Preload("Orders.Items")
+// 每一层关联只会执行一次 IN (...) 查询，多对多会额外查询一次中间表
+func (s *Session) Preload(name string) *Session {
+	s.preloads = append(s.preloads, name)
+	return s
+}
+
+// preload为values中的每个结构体加载paths指定的关联，values是结构体切片
+func (s *Session) preload(table *schema.Schema, values reflect.Value, paths []string) error {
+	var names []string
+	nested := map[string][]string{}
+	for _, path := range paths {
+		parts := strings.SplitN(path, ".", 2)
+		if _, ok := nested[parts[0]]; !ok {
+			names = append(names, parts[0])
+			nested[parts[0]] = nil
+		}
+		if len(parts) == 2 {
+			nested[parts[0]] = append(nested[parts[0]], parts[1])
+		}
+	}
+
+	for _, name := range names {
+		rel := table.GetRelation(name)
+		if rel == nil {
+			return fmt.Errorf("preload: %s has no relation %s", table.ModelType.Name(), name)
+		}
+		if err := s.preloadRelation(rel, values, nested[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preloadRelation查询rel关联的数据，先为它们加载嵌套的关联，再写入values中对应的字段
+func (s *Session) preloadRelation(rel *schema.Relation, values reflect.Value, nested []string) error {
+	if values.Len() == 0 {
+		return nil
+	}
+
+	// 当前模型中用于匹配的字段，以及关联模型中用于匹配的列
+	ownerKey, relKey := rel.References, rel.ForeignKey
+	switch rel.Type {
+	case schema.BelongsTo:
+		ownerKey, relKey = rel.ForeignKey, rel.References
+	case schema.Many2Many:
+		relKey = rel.Schema.PrimaryField
+	}
+
+	var keys []interface{}
+	seen := map[string]bool{}
+	for i := 0; i < values.Len(); i++ {
+		v := ownerKey.ValueOf(values.Index(i))
+		if k := keyOf(v); !seen[k] && !(rel.Type == schema.BelongsTo && v.IsZero()) {
+			seen[k] = true
+			keys = append(keys, v.Interface())
+		}
+	}
+
+	// 多对多先从中间表查出 当前模型的键 -> 关联模型的键
+	var joins map[string][]string
+	if rel.Type == schema.Many2Many {
+		var err error
+		if joins, keys, err = s.joinKeys(rel, keys); err != nil {
+			return err
+		}
+	}
+
+	related := reflect.New(reflect.SliceOf(rel.Schema.ModelType))
+	if len(keys) > 0 {
+		err := s.clone().Where(fmt.Sprintf("%s IN (?)", s.dialect.Quote(relKey.Name)), keys).Find(related.Interface())
+		if err != nil {
+			return err
+		}
+	}
+	related = related.Elem()
+	if len(nested) > 0 {
+		if err := s.preload(rel.Schema, related, nested); err != nil {
+			return err
+		}
+	}
+
+	// 关联模型的键 -> 关联的数据
+	group := map[string][]reflect.Value{}
+	for i := 0; i < related.Len(); i++ {
+		k := keyOf(relKey.ValueOf(related.Index(i)))
+		group[k] = append(group[k], related.Index(i))
+	}
+
+	for i := 0; i < values.Len(); i++ {
+		owner := values.Index(i)
+		k := keyOf(ownerKey.ValueOf(owner))
+		matched := group[k]
+		if rel.Type == schema.Many2Many {
+			matched = nil
+			for _, relK := range joins[k] {
+				matched = append(matched, group[relK]...)
+			}
+		}
+		setRelation(rel, owner, matched)
+	}
+	return nil
+}
+
+// joinKeys从中间表查询keys关联的数据，返回 当前模型的键 -> 关联模型的键，以及所有关联模型的键
+func (s *Session) joinKeys(rel *schema.Relation, keys []interface{}) (map[string][]string, []interface{}, error) {
+	joins := map[string][]string{}
+	if len(keys) == 0 {
+		return joins, nil, nil
+	}
+
+	fk, ref := s.dialect.Quote(rel.JoinForeignKey), s.dialect.Quote(rel.JoinReferences)
+	sql := fmt.Sprintf("SELECT %s, %s FROM %s", fk, ref, s.dialect.Quote(rel.JoinTable))
+	sub := s.clone()
+	sub.clause.Where().Where(fk+" IN (?)", keys)
+	where, vars := sub.clause.Build(clause.WHERE)
+	rows, err := sub.Raw(sql+" "+where, vars...).QueryRows()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var relKeys []interface{}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var ownerK, relK interface{}
+		if err := rows.Scan(&ownerK, &relK); err != nil {
+			return nil, nil, err
+		}
+		ownerKey, k := keyOf(reflect.ValueOf(ownerK)), keyOf(reflect.ValueOf(relK))
+		joins[ownerKey] = append(joins[ownerKey], k)
+		if !seen[k] {
+			seen[k] = true
+			relKeys = append(relKeys, relK)
+		}
+	}
+	return joins, relKeys, rows.Err()
+}
+
+// setRelation将matched写入owner的关联字段，matched中是关联模型的结构体
+func setRelation(rel *schema.Relation, owner reflect.Value, matched []reflect.Value) {
+	field := rel.Settable(owner)
+	switch field.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), 0, len(matched))
+		for _, m := range matched {
+			slice = reflect.Append(slice, elemOf(field.Type().Elem(), m))
+		}
+		field.Set(slice)
+	default:
+		if len(matched) == 0 {
+			field.Set(reflect.Zero(field.Type()))
+			return
+		}
+		field.Set(elemOf(field.Type(), matched[0]))
+	}
+}
+
+// elemOf将结构体v转化为typ类型，typ是结构体或者结构体指针
+func elemOf(typ reflect.Type, v reflect.Value) reflect.Value {
+	if typ.Kind() == reflect.Ptr {
+		return v.Addr()
+	}
+	return v
+}
+
+// keyOf将键转化为字符串，这样int和int64等类型不同的键也可以匹配
+// 数据库驱动返回的[]byte会被视为字符串
+func keyOf(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	if b, ok := v.Interface().([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
@@ -17,12 +18,29 @@ type Session struct {
 	sqlVars []interface{}
 
 	dialect dialect.Dialect //适配不同的sql语言
-	clause  clause.Clause   //构造sql语句
+	clause  *clause.Clause  //构造sql语句
 
-	refTable *schema.Schema //不同结构体反射的Schema对象
+	refTable *schema.Schema    //不同结构体反射的Schema对象
+	schemas  *schema.Cache     //解析过的Schema，同时决定表名和列名
+	preloads []string          //Find时需要预加载的关联
+	joins    []join            //Find和Count时需要连接的表
+	selects  []string          //Select指定的列或者表达式
+	groups   []string          //GroupBy指定的列
+	omits    []string          //Omit指定不查询的列
+	distinct bool              //Distinct指定查询时去重
+	conflict *dialect.Conflict //Insert冲突时的处理方式
+	model    interface{}       //最近一次传给Model的对象，Association在它上面操作关联
+	modelSet bool              //本次操作调用过Model，Clear时重置
+
+	callbacks *Callbacks //增删查改执行的回调
+	statement *Statement //正在执行的操作的数据
+
+	allowGlobalUpdate bool //允许没有条件的Update和Delete
 
 	history strings.Builder //用于记录历史执行了的sql语句
 	tx      *sql.Tx         //事务
+	txState *txState        //事务中共享的状态
+	ctx     context.Context //控制sql执行的取消和超时
 
 	// 在钩子函数中关闭后续操作
 	Abort bool
@@ -38,6 +56,9 @@ type CommonDB interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
 var _ CommonDB = (*sql.DB)(nil)
@@ -52,21 +73,76 @@ func (s *Session) DB() CommonDB {
 }
 
 // New生成一个新的Session
-func New(db *sql.DB, dialect dialect.Dialect) *Session {
-	return &Session{
+func New(db *sql.DB, dialect dialect.Dialect, opts ...Option) *Session {
+	s := &Session{
 		db:      db,
 		dialect: dialect,
+		clause:  clause.New(dialect),
+		schemas: schema.NewCache(nil),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.callbacks == nil {
+		s.callbacks = NewCallbacks()
+	}
+	return s
+}
+
+// WithContext为Session设置上下文，之后执行的sql语句都会受它控制
+// 钩子函数中可以通过 s.Context() 拿到它
+func (s *Session) WithContext(ctx context.Context) *Session {
+	s.ctx = ctx
+	return s
+}
+
+// Context返回Session的上下文，没有设置时返回context.Background()
+func (s *Session) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
 	}
+	return s.ctx
 }
 
 // Clear将会把一个Session还原为新的Session，但保留基本配置
 func (s *Session) Clear() {
 	s.sql.Reset()
 	s.sqlVars = nil
-	s.clause = clause.Clause{}
+	s.clause = clause.New(s.dialect)
+	s.preloads = nil
+	s.joins = nil
+	s.selects = nil
+	s.groups = nil
+	s.omits = nil
+	s.distinct = false
+	s.conflict = nil
+	s.modelSet = false
+	s.allowGlobalUpdate = false
 	s.Abort = false
 }
 
+// clone生成一个新的Session，它和s共享连接、事务、上下文和配置
+// 用于在一次操作中执行额外的sql，比如预加载关联
+func (s *Session) clone() *Session {
+	return &Session{
+		db:            s.db,
+		dialect:       s.dialect,
+		clause:        clause.New(s.dialect),
+		schemas:       s.schemas,
+		callbacks:     s.callbacks,
+		tx:            s.tx,
+		txState:       s.txState,
+		ctx:           s.ctx,
+		EnableHistory: s.EnableHistory,
+		EnableHook:    s.EnableHook,
+	}
+}
+
+// query返回最终交给数据库执行的sql语句，占位符已经按方言改写
+func (s *Session) query() string {
+	return dialect.Rebind(s.dialect, s.sql.String())
+}
+
 // Raw将sql语句和变量保存在Session中
 func (s *Session) Raw(sql string, values ...interface{}) *Session {
 	s.sql.WriteString(sql)
@@ -79,16 +155,17 @@ func (s *Session) Raw(sql string, values ...interface{}) *Session {
 // 最后会清理Session中的sql语句和变量
 func (s *Session) Exec() (resout sql.Result, err error) {
 	defer s.Clear()
+	query := s.query()
 	if s.Abort {
 		err = errors.New("Abort")
-		log.Error("Abort: ", s.sql.String(), s.sqlVars)
+		log.Error("Abort: ", query, s.sqlVars)
 		return
 	}
-	log.Info(s.sql.String(), s.sqlVars)
+	log.Info(query, s.sqlVars)
 	if s.EnableHistory {
-		s.recordSql(s.sql.String(), s.sqlVars)
+		s.recordSql(query, s.sqlVars)
 	}
-	if resout, err = s.DB().Exec(s.sql.String(), s.sqlVars...); err != nil {
+	if resout, err = s.DB().ExecContext(s.Context(), query, s.sqlVars...); err != nil {
 		log.Error(err)
 	}
 	return
@@ -96,30 +173,32 @@ func (s *Session) Exec() (resout sql.Result, err error) {
 
 func (s *Session) QueryRow() *sql.Row {
 	defer s.Clear()
+	query := s.query()
 	if s.Abort {
 
-		log.Error("Abort: ", s.sql.String(), s.sqlVars)
+		log.Error("Abort: ", query, s.sqlVars)
 		return nil
 	}
-	log.Info(s.sql.String(), s.sqlVars)
+	log.Info(query, s.sqlVars)
 	if s.EnableHistory {
-		s.recordSql(s.sql.String(), s.sqlVars)
+		s.recordSql(query, s.sqlVars)
 	}
-	return s.DB().QueryRow(s.sql.String(), s.sqlVars...)
+	return s.DB().QueryRowContext(s.Context(), query, s.sqlVars...)
 }
 
 func (s *Session) QueryRows() (rows *sql.Rows, err error) {
 	defer s.Clear()
+	query := s.query()
 	if s.Abort {
 		err = errors.New("Abort")
-		log.Error("Abort: ", s.sql.String(), s.sqlVars)
+		log.Error("Abort: ", query, s.sqlVars)
 		return
 	}
-	log.Info(s.sql.String(), s.sqlVars)
+	log.Info(query, s.sqlVars)
 	if s.EnableHistory {
-		s.recordSql(s.sql.String(), s.sqlVars)
+		s.recordSql(query, s.sqlVars)
 	}
-	if rows, err = s.DB().Query(s.sql.String(), s.sqlVars...); err != nil {
+	if rows, err = s.DB().QueryContext(s.Context(), query, s.sqlVars...); err != nil {
 		log.Error(err)
 	}
 	return
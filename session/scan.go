@@ -0,0 +1,94 @@
+package session
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+
+	"github.com/tomygin/borm/schema"
+)
+
+// joined记录一个连接的关联在当前行中扫描到的列
+type joined struct {
+	rel    *schema.Relation
+	fields []*schema.Field
+	values []reflect.Value //指向字段类型指针的指针，列为NULL时指针为nil
+}
+
+// scanRow将rows的当前行按列名写入结构体dest，columns是rows.Columns()
+// 列名可以是table中的列名，也可以是连接的关联的列 Profile__bio，无法识别的列会被忽略
+func scanRow(rows *sql.Rows, table *schema.Schema, dest reflect.Value, columns []string) error {
+	values := make([]interface{}, len(columns))
+	var joins []*joined
+	for i, name := range columns {
+		if field := table.GetField(name); field != nil {
+			values[i] = field.Settable(dest).Addr().Interface()
+			continue
+		}
+		if j, field := joinedField(table, &joins, name); field != nil {
+			v := reflect.New(reflect.PtrTo(field.GoType))
+			j.fields, j.values = append(j.fields, field), append(j.values, v)
+			values[i] = v.Interface()
+			continue
+		}
+		values[i] = new(interface{})
+	}
+	if err := rows.Scan(values...); err != nil {
+		return err
+	}
+
+	for _, j := range joins {
+		j.assign(dest)
+	}
+	return nil
+}
+
+// joinedField根据 Profile__bio 这样的列名找到连接的关联和它的字段
+func joinedField(table *schema.Schema, joins *[]*joined, name string) (*joined, *schema.Field) {
+	parts := strings.SplitN(name, joinSep, 2)
+	if len(parts) != 2 {
+		return nil, nil
+	}
+	rel := table.GetRelation(parts[0])
+	if rel == nil || (rel.Type != schema.HasOne && rel.Type != schema.BelongsTo) {
+		return nil, nil
+	}
+	field := rel.Schema.GetField(parts[1])
+	if field == nil {
+		return nil, nil
+	}
+	for _, j := range *joins {
+		if j.rel == rel {
+			return j, field
+		}
+	}
+	j := &joined{rel: rel}
+	*joins = append(*joins, j)
+	return j, field
+}
+
+// assign将扫描到的列写入dest的关联字段，所有的列都是NULL时说明没有连接到数据
+func (j *joined) assign(dest reflect.Value) {
+	found := false
+	for _, v := range j.values {
+		if !v.Elem().IsNil() {
+			found = true
+		}
+	}
+	if !found {
+		return
+	}
+
+	target := j.rel.Settable(dest)
+	if target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		target = target.Elem()
+	}
+	for i, field := range j.fields {
+		if v := j.values[i].Elem(); !v.IsNil() {
+			field.Settable(target).Set(v.Elem())
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package session
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tomygin/borm/clause"
+	"github.com/tomygin/borm/dialect"
+)
+
+// newSqliteSession生成一个使用临时sqlite数据库的Session，并为models建表
+func newSqliteSession(t *testing.T, models ...interface{}) *Session {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	dial, _ := dialect.GetDialect("sqlite")
+	s := New(db, dial)
+	for _, model := range models {
+		if err := s.Model(model).CreateTable(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+type batchUser struct {
+	ID   int64 `borm:"primaryKey;autoIncrement"`
+	Name string
+	Age  int `borm:"default:18"`
+}
+
+func TestInsertMixedGeneratedFields(t *testing.T) {
+	s := newSqliteSession(t, &batchUser{})
+
+	users := []*batchUser{{ID: 10, Name: "a"}, {Name: "b"}, {Name: "c", Age: 5}, {Name: "d"}}
+	affected, err := s.Insert(users[0], users[1], users[2], users[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 4 {
+		t.Errorf("affected = %d, want 4", affected)
+	}
+	for i, want := range []int64{10, 11, 12, 13} {
+		if users[i].ID != want {
+			t.Errorf("users[%d].ID = %d, want %d", i, users[i].ID, want)
+		}
+	}
+
+	var got []batchUser
+	if err := s.OrderBy("id").Find(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []batchUser{{10, "a", 18}, {11, "b", 18}, {12, "c", 5}, {13, "d", 18}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+}
+
+func TestInsertMixedBatchIsAtomic(t *testing.T) {
+	s := newSqliteSession(t, &batchUser{})
+	if _, err := s.Insert(&batchUser{ID: 2, Name: "exists"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二批的主键冲突时，第一批也不会被插入
+	if _, err := s.Insert(&batchUser{Name: "a"}, &batchUser{ID: 2, Name: "b"}); err == nil {
+		t.Fatal("expected a unique constraint error")
+	}
+	count, err := s.Model(&batchUser{}).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+}
+
+type upsertUser struct {
+	ID   int64  `borm:"primaryKey;autoIncrement"`
+	Name string `borm:"unique"`
+	Age  int
+}
+
+// sqlite中RETURNING的顺序不确定，写回的主键要和每一行对应
+func TestInsertBackfillsEachRow(t *testing.T) {
+	s := newSqliteSession(t, &upsertUser{})
+	if _, err := s.Insert(&upsertUser{ID: 100, Name: "exists"}); err != nil {
+		t.Fatal(err)
+	}
+
+	users := []*upsertUser{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+	affected, err := s.Insert(users[0], users[1], users[2], users[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 4 {
+		t.Errorf("affected = %d, want 4", affected)
+	}
+	for _, user := range users {
+		var got upsertUser
+		if err := s.Where("id = ?", user.ID).First(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != user.Name {
+			t.Errorf("row %d is %q, want %q", user.ID, got.Name, user.Name)
+		}
+	}
+}
+
+func TestUpsertBackfillsPrimary(t *testing.T) {
+	s := newSqliteSession(t, &upsertUser{})
+	exists := &upsertUser{Name: "a", Age: 1}
+	if _, err := s.Insert(exists, &upsertUser{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 冲突时更新已有的行，写回的是它的主键
+	user := &upsertUser{Name: "a", Age: 2}
+	if _, err := s.OnConflict(clause.Columns("name")).DoUpdate("age").Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != exists.ID {
+		t.Errorf("ID = %d, want %d", user.ID, exists.ID)
+	}
+
+	// 忽略的行不写回主键
+	ignored := &upsertUser{Name: "b"}
+	if _, err := s.OnConflict(clause.Columns("name")).DoNothing().Insert(ignored); err != nil {
+		t.Fatal(err)
+	}
+	if ignored.ID != 0 {
+		t.Errorf("ID = %d, want 0", ignored.ID)
+	}
+}
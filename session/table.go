@@ -11,8 +11,10 @@ import (
 
 // 如果当前对象没有被解析为Schema就解析
 func (s *Session) Model(value interface{}) *Session {
-	if s.refTable == nil || reflect.TypeOf(value) != reflect.TypeOf(s.refTable.Model) {
-		s.refTable = schema.Parse(value, s.dialect)
+	s.model, s.modelSet = value, true
+	if s.refTable == nil || reflect.Indirect(reflect.ValueOf(value)).Type() != s.refTable.ModelType {
+		s.refTable = s.schemas.Parse(value, s.dialect)
+		checkHooks(s.refTable.ModelType)
 	}
 	return s
 }
@@ -29,23 +31,51 @@ func (s *Session) CreateTable() error {
 	table := s.RefTable()
 	var col []string
 	for _, field := range table.Fields {
-		col = append(col, fmt.Sprintf("%s %s %s", field.Name, field.Type, field.Tag))
+		col = append(col, fmt.Sprintf("%s %s", s.dialect.Quote(field.Name), s.dialect.ColumnSql(&field.Column)))
 	}
 
 	desc := strings.Join(col, ",")
-	_, err := s.Raw(fmt.Sprintf("CREATE TABLE %s (%s);", table.Name, desc)).Exec()
+	if _, err := s.Raw(fmt.Sprintf("CREATE TABLE %s (%s);", s.dialect.Quote(table.Name), desc)).Exec(); err != nil {
+		return err
+	}
+
+	for _, index := range table.Indexes {
+		if err := s.createIndex(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIndex创建当前表的一个索引
+func (s *Session) createIndex(index *schema.Index) error {
+	var cols []string
+	for _, field := range index.Fields {
+		cols = append(cols, s.dialect.Quote(field.Name))
+	}
+
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	_, err := s.Raw(fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique,
+		s.dialect.Quote(index.Name), s.dialect.Quote(s.RefTable().Name), strings.Join(cols, ","))).Exec()
 	return err
 }
 
 func (s *Session) DropTable() error {
-	_, err := s.Raw(fmt.Sprintf("DROP TABLE IF EXISTS %s", s.RefTable().Name)).Exec()
+	_, err := s.Raw(fmt.Sprintf("DROP TABLE IF EXISTS %s", s.dialect.Quote(s.RefTable().Name))).Exec()
 	return err
 }
 
 func (s *Session) IsExistTable() bool {
-	sql, values := s.dialect.TableExistSql(s.RefTable().Name)
+	return s.tableExists(s.RefTable().Name)
+}
+
+func (s *Session) tableExists(name string) bool {
+	sql, values := s.dialect.TableExistSql(name)
 	row := s.Raw(sql, values...).QueryRow()
 	var tmp string
 	row.Scan(&tmp)
-	return tmp == s.RefTable().Name
+	return tmp == name
 }
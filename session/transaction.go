@@ -1,23 +1,63 @@
 package session
 
-import "github.com/tomygin/borm/log"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
 
+	"github.com/tomygin/borm/log"
+)
+
+// txState是同一个事务中的Session共享的状态
+type txState struct {
+	err        error           //After钩子返回的第一个错误，Commit时回滚并返回它
+	savepoints int             //创建过的保存点数量，用于生成保存点的名字
+	end        func(err error) //方言开启事务时返回的清理函数，事务结束后调用
+}
+
+// release在事务提交或者回滚之后调用方言的清理函数，err是提交或者回滚的错误
+func (t *txState) release(err error) {
+	if t.end != nil {
+		t.end(err)
+	}
+}
+
+// Begin开启事务，Session已经在事务中时返回错误，嵌套的事务使用Transaction
 func (s *Session) Begin() (err error) {
+	return s.BeginTx(nil)
+}
+
+// BeginTx和Begin一样，但可以指定事务的隔离级别和是否只读，opts为nil时使用驱动的默认值
+func (s *Session) BeginTx(opts *sql.TxOptions) (err error) {
+	if s.tx != nil {
+		err = errors.New("transaction already begun, use Transaction for nested transactions")
+		log.Error(err)
+		return
+	}
 	log.Info("transaction begin")
-	s.tx, err = s.db.Begin()
+	tx, end, err := s.dialect.BeginTx(s.Context(), s.db, opts)
 	if err != nil {
 		log.Error(err)
+		return
 	}
-
+	s.tx, s.txState = tx, &txState{end: end}
 	return
 }
 
+// Commit提交事务，事务中的After钩子返回过错误时会回滚，并返回这个错误
 func (s *Session) Commit() (err error) {
+	if s.txState != nil && s.txState.err != nil {
+		err = s.txState.err
+		_ = s.RollBack()
+		return
+	}
 	log.Info("transaction commit")
 	err = s.tx.Commit()
 	if err != nil {
 		log.Error(err)
 	}
+	s.txState.release(err)
+	s.tx, s.txState = nil, nil
 
 	return
 }
@@ -28,6 +68,70 @@ func (s *Session) RollBack() (err error) {
 	if err != nil {
 		log.Error(err)
 	}
+	s.txState.release(err)
+	s.tx, s.txState = nil, nil
 
 	return
 }
+
+// Transaction在事务中执行fn，fn返回错误或者panic时回滚，否则提交
+// Session不在事务中时开启一个新的事务，已经在事务中时使用保存点，
+// 嵌套的Transaction失败只回滚到它的保存点，外层的事务可以继续
+func (s *Session) Transaction(fn func(s *Session) error) error {
+	return s.TransactionWith(nil, fn)
+}
+
+// TransactionWith和Transaction一样，但开启新的事务时使用opts，嵌套的事务使用外层事务的选项
+func (s *Session) TransactionWith(opts *sql.TxOptions, fn func(s *Session) error) (err error) {
+	if s.tx == nil {
+		if err = s.BeginTx(opts); err != nil {
+			return
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				_ = s.RollBack()
+				panic(p)
+			} else if err != nil {
+				_ = s.RollBack()
+			} else {
+				err = s.Commit()
+			}
+		}()
+		return fn(s)
+	}
+
+	state := s.txState
+	state.savepoints++
+	name := fmt.Sprintf("borm_sp%d", state.savepoints)
+	if _, err = s.clone().Raw(s.dialect.SavepointSql(name)).Exec(); err != nil {
+		return
+	}
+	// 保存点之前After钩子的错误仍然会让整个事务回滚，保存点之后的错误只回滚到保存点
+	hookErr := state.err
+	defer func() {
+		p := recover()
+		if err == nil && p == nil && state.err != hookErr {
+			err = state.err
+		}
+		if err != nil || p != nil {
+			state.err = hookErr
+			if _, rbErr := s.clone().Raw(s.dialect.RollbackToSql(name)).Exec(); rbErr != nil {
+				state.err = rbErr
+			}
+		} else {
+			// postgres的每个保存点都是一个子事务，成功后及时释放，不要留到提交
+			_, err = s.clone().Raw(s.dialect.ReleaseSavepointSql(name)).Exec()
+		}
+		if p != nil {
+			panic(p)
+		}
+	}()
+	return fn(s)
+}
+
+// failTx记录事务中After钩子返回的错误，不在事务中时什么也不做
+func (s *Session) failTx(err error) {
+	if s.tx != nil && s.txState != nil && s.txState.err == nil {
+		s.txState.err = err
+	}
+}